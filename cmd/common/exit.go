@@ -0,0 +1,27 @@
+package common
+
+import "fmt"
+
+const (
+	ExitError            = 1  // the run could not be started or its results could not be written
+	ExitThresholdsFailed = 99 // the run completed but at least one --threshold was violated
+	ExitRegression       = 98 // compare found a regression against the baseline
+)
+
+// ExitCodeError is returned by a command that must end the process with a specific exit code.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+func thresholdsFailed(failed int) error {
+	return &ExitCodeError{Code: ExitThresholdsFailed, Err: fmt.Errorf("%d threshold(s) failed", failed)}
+}
@@ -0,0 +1,261 @@
+package common
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"generator/load/src/report"
+	"generator/load/src/sink"
+	"generator/load/src/stats"
+	"generator/load/src/telemetry"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+// AddOutputFlags registers the flags controlling how the results of a run are reported,
+// shared by every load command.
+func AddOutputFlags(cmd *cobra.Command) {
+	var output string
+	var out string
+	var rawOut string
+	var html string
+	var junit string
+	var progress string
+	var interval time.Duration
+	var timeline bool
+	var errorSamples int
+	var metricsAddr string
+	var otlpEndpoint string
+	var otlpProtocol string
+	var otlpSample float64
+	var thresholds []string
+	var runId string
+	var influx string
+	var influxToken string
+
+	cmd.Flags().StringVar(&output, "output", OutputText, "Summary format: text or json")
+	cmd.Flags().StringVar(&out, "out", "", "File to write the summary to, the text summary is still printed to stdout")
+	cmd.Flags().StringVar(&rawOut, "raw-out", "", "File to stream every request result to, .csv or .jsonl")
+	cmd.Flags().StringVar(&html, "html", "", "File to write a self-contained HTML report with charts to")
+	cmd.Flags().StringVar(&junit, "junit", "", "File to write a JUnit XML report to, one test case per threshold")
+	cmd.Flags().DurationVar(&interval, "interval", time.Second, "Width of the time-series buckets reported in the JSON and HTML outputs and by --timeline")
+	cmd.Flags().BoolVar(&timeline, "timeline", false, "Print the per-interval throughput, errors and latency percentiles as a table after the text summary")
+	cmd.Flags().IntVar(&errorSamples, "error-samples", 5, "Failed requests kept per error class, with the start of their response body, as samples in the summary")
+	cmd.Flags().StringVar(&progress, "progress", "", "Show live progress on stderr: auto, tui or line, plain --progress means auto")
+	cmd.Flags().Lookup("progress").NoOptDefVal = sink.ProgressAuto
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Address to expose Prometheus metrics on /metrics while the load runs, e.g. :9100")
+	cmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OpenTelemetry collector to export run metrics to, e.g. localhost:4317 or https://collector:4318")
+	cmd.Flags().StringVar(&otlpProtocol, "otlp-protocol", telemetry.ProtocolGrpc, "OTLP transport: grpc or http")
+	cmd.Flags().Float64Var(&otlpSample, "otlp-trace-sample", 0, "Fraction of requests exported as client spans with a W3C traceparent sent to the server, 0 disables traces")
+	cmd.Flags().StringVar(&runId, "run-id", "", "Identifier of the run in the JSON summary and exported metrics, generated when empty")
+	cmd.Flags().StringVar(&influx, "influx", "", "InfluxDB line protocol output, every --interval: a file path or an HTTP write URL such as http://localhost:8086/api/v2/write?org=acme&bucket=bench")
+	cmd.Flags().StringVar(&influxToken, "influx-token", "", "InfluxDB API token sent with --influx HTTP writes")
+	cmd.Flags().StringArrayVar(&thresholds, "threshold", nil, `Condition the run must meet, e.g. "p99<250ms", "error_rate<1%" or "rps>400", repeatable, a violation exits with code 99`)
+}
+
+// CheckOutputFlags validates the output flags, so a bad value fails before the load is generated.
+func CheckOutputFlags(cmd *cobra.Command) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != OutputText && output != OutputJson {
+		return fmt.Errorf("invalid --output %q, expected text or json", output)
+	}
+	progress, _ := cmd.Flags().GetString("progress")
+	if progress != "" && !sink.ValidProgressMode(progress) {
+		return fmt.Errorf("invalid --progress %q, expected auto, tui or line", progress)
+	}
+	interval, _ := cmd.Flags().GetDuration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("invalid --interval %s, expected a positive duration", interval)
+	}
+	otlpSample, _ := cmd.Flags().GetFloat64("otlp-trace-sample")
+	if otlpSample < 0 || otlpSample > 1 {
+		return fmt.Errorf("invalid --otlp-trace-sample %g, expected a fraction between 0 and 1", otlpSample)
+	}
+	_, err := parseThresholds(cmd)
+	return err
+}
+
+func parseThresholds(cmd *cobra.Command) ([]stats.Threshold, error) {
+	expressions, _ := cmd.Flags().GetStringArray("threshold")
+	thresholds := make([]stats.Threshold, 0, len(expressions))
+	for _, expression := range expressions {
+		threshold, err := stats.ParseThreshold(expression)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
+
+// NewCollector creates the collector of a run with the sinks requested by the output flags attached.
+func NewCollector(cmd *cobra.Command, config stats.RunConfig) (*stats.Collector, error) {
+	config.RunId, _ = cmd.Flags().GetString("run-id")
+	if config.RunId == "" {
+		config.RunId = newRunId()
+	}
+	slog.Info("starting run", "run_id", config.RunId, "protocol", config.Protocol, "mode", config.Mode,
+		"target", config.Target, "method", config.Method, "requests", config.Requests, "rate", config.Rate)
+	collector := stats.NewCollector(config)
+	interval, _ := cmd.Flags().GetDuration("interval")
+	collector.SetInterval(interval)
+	errorSamples, _ := cmd.Flags().GetInt("error-samples")
+	collector.SetErrorSamples(errorSamples)
+
+	rawOut, _ := cmd.Flags().GetString("raw-out")
+	if rawOut != "" {
+		raw, err := sink.NewRawWriter(rawOut)
+		if err != nil {
+			return nil, err
+		}
+		collector.AddSink(raw)
+	}
+
+	metricsAddr, _ := cmd.Flags().GetString("metrics-addr")
+	if metricsAddr != "" {
+		prometheus, err := sink.NewPrometheus(collector, metricsAddr)
+		if err != nil {
+			return nil, err
+		}
+		collector.AddSink(prometheus)
+	}
+
+	influx, _ := cmd.Flags().GetString("influx")
+	if influx != "" {
+		influxToken, _ := cmd.Flags().GetString("influx-token")
+		writer, err := sink.NewInflux(collector, influx, influxToken, interval)
+		if err != nil {
+			return nil, err
+		}
+		collector.AddSink(writer)
+	}
+
+	otlpEndpoint, _ := cmd.Flags().GetString("otlp-endpoint")
+	if otlpEndpoint != "" {
+		otlpProtocol, _ := cmd.Flags().GetString("otlp-protocol")
+		otlpSample, _ := cmd.Flags().GetFloat64("otlp-trace-sample")
+		exporter, err := telemetry.Start(telemetry.Config{
+			Endpoint:    otlpEndpoint,
+			Protocol:    otlpProtocol,
+			SampleRatio: otlpSample,
+		}, collector)
+		if err != nil {
+			return nil, err
+		}
+		collector.AddSink(exporter)
+	}
+
+	progress, _ := cmd.Flags().GetString("progress")
+	if progress != "" {
+		collector.AddSink(sink.NewProgress(collector, progress, os.Stderr))
+	}
+
+	return collector, nil
+}
+
+// newRunId names a run after its start time, with a random suffix so parallel runs don't collide.
+func newRunId() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%x", time.Now().UTC().Format("20060102T150405"), suffix)
+}
+
+// Finish stops the collector of a finished run, evaluates its thresholds and reports its summary
+// as requested by the output flags. A violated threshold is returned as an ExitCodeError.
+func Finish(cmd *cobra.Command, collector *stats.Collector) error {
+	summary, sinkErr := collector.Stop()
+	slog.Info("run finished", "duration", summary.Duration, "requests", summary.Requests, "failed", summary.Failed)
+	thresholds, err := parseThresholds(cmd)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, threshold := range thresholds {
+		result := threshold.Evaluate(summary)
+		if !result.Passed {
+			failed++
+		}
+		summary.Thresholds = append(summary.Thresholds, result)
+	}
+
+	if err := writeSummary(cmd, summary); err != nil {
+		return err
+	}
+
+	html, _ := cmd.Flags().GetString("html")
+	if html != "" {
+		if err := writeFile(html, summary, report.WriteHtml); err != nil {
+			return err
+		}
+	}
+	junit, _ := cmd.Flags().GetString("junit")
+	if junit != "" {
+		if err := writeFile(junit, summary, report.WriteJunit); err != nil {
+			return err
+		}
+	}
+	if sinkErr != nil {
+		return sinkErr
+	}
+	if failed > 0 {
+		// The table already says which thresholds failed, the usage text would only bury it.
+		cmd.SilenceUsage = true
+		return thresholdsFailed(failed)
+	}
+	return nil
+}
+
+func writeSummary(cmd *cobra.Command, summary *stats.Summary) error {
+	output, _ := cmd.Flags().GetString("output")
+	out, _ := cmd.Flags().GetString("out")
+
+	timeline, _ := cmd.Flags().GetBool("timeline")
+	printText := func(w io.Writer, s *stats.Summary) error {
+		s.Print(w)
+		if timeline {
+			s.PrintTimeline(w)
+		}
+		if len(s.Thresholds) > 0 {
+			s.PrintThresholds(w)
+		}
+		return nil
+	}
+
+	if out == "" {
+		if output == OutputJson {
+			// stdout only carries the JSON, the verdict still shows up on the terminal
+			if len(summary.Thresholds) > 0 {
+				summary.PrintThresholds(os.Stderr)
+			}
+			return report.WriteJson(os.Stdout, summary)
+		}
+		return printText(os.Stdout, summary)
+	}
+
+	printText(os.Stdout, summary)
+	if output == OutputJson {
+		return writeFile(out, summary, report.WriteJson)
+	}
+	return writeFile(out, summary, printText)
+}
+
+func writeFile(path string, summary *stats.Summary, write func(io.Writer, *stats.Summary) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file, summary); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"generator/load/src/retry"
+
+	"github.com/spf13/cobra"
+)
+
+// AddRetryFlags registers the flags of the retry policy next to the --maxr flag every command
+// registers with its own default.
+func AddRetryFlags(cmd *cobra.Command) {
+	var backoff time.Duration
+	var maxBackoff time.Duration
+	var jitter float64
+	var retryOn string
+	var tryTimeout time.Duration
+
+	cmd.Flags().DurationVar(&backoff, "retry-backoff", 100*time.Millisecond, "Delay before the first retry, doubled for every further retry")
+	cmd.Flags().DurationVar(&maxBackoff, "retry-max-backoff", 2*time.Second, "Maximum delay between two attempts, 0 for no maximum")
+	cmd.Flags().Float64Var(&jitter, "retry-jitter", 0.5, "Fraction of every retry delay that is randomized, from 0 to 1")
+	cmd.Flags().StringVar(&retryOn, "retry-on", retry.DefaultRetryOn, "Error classes and status codes worth retrying, comma separated")
+	cmd.Flags().DurationVar(&tryTimeout, "try-timeout", 0, "Time limit of a single attempt, 0 uses --timeout for requests and none for streams")
+}
+
+// RetryPolicy reads the retry policy from the flags, tryTimeout is the attempt limit used when
+// --try-timeout isn't set.
+func RetryPolicy(cmd *cobra.Command, tryTimeout time.Duration) (retry.Policy, error) {
+	maxRetries, _ := cmd.Flags().GetInt("maxr")
+	backoff, _ := cmd.Flags().GetDuration("retry-backoff")
+	maxBackoff, _ := cmd.Flags().GetDuration("retry-max-backoff")
+	jitter, _ := cmd.Flags().GetFloat64("retry-jitter")
+	retryOnList, _ := cmd.Flags().GetString("retry-on")
+	if flagTimeout, _ := cmd.Flags().GetDuration("try-timeout"); flagTimeout > 0 {
+		tryTimeout = flagTimeout
+	}
+	if maxRetries < 0 {
+		return retry.Policy{}, fmt.Errorf("invalid --maxr %d, expected 0 or more retries", maxRetries)
+	}
+	if backoff < 0 || maxBackoff < 0 {
+		return retry.Policy{}, fmt.Errorf("invalid retry backoff, expected positive durations")
+	}
+	if jitter < 0 || jitter > 1 {
+		return retry.Policy{}, fmt.Errorf("invalid --retry-jitter %g, expected a fraction between 0 and 1", jitter)
+	}
+	retryOn, err := retry.ParseRetryOn(retryOnList)
+	if err != nil {
+		return retry.Policy{}, err
+	}
+	return retry.Policy{
+		MaxRetries: maxRetries,
+		Backoff:    backoff,
+		MaxBackoff: maxBackoff,
+		Jitter:     jitter,
+		TryTimeout: tryTimeout,
+		RetryOn:    retryOn,
+	}, nil
+}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"fmt"
+
+	"generator/load/src/stats"
+
+	"github.com/spf13/cobra"
+)
+
+// AddScheduleFlags registers --rate and --latency, rateHelp describes what --rate dispatches.
+func AddScheduleFlags(cmd *cobra.Command, rateHelp string) {
+	var rate float64
+	var latencyMode string
+
+	cmd.Flags().Float64Var(&rate, "rate", 0, rateHelp)
+	cmd.Flags().StringVar(&latencyMode, "latency", stats.LatencyCorrected, "Latency distribution to report: corrected, uncorrected or both")
+}
+
+// Schedule reads the dispatch rate and the latency mode from the flags.
+func Schedule(cmd *cobra.Command) (float64, string, error) {
+	rate, _ := cmd.Flags().GetFloat64("rate")
+	latencyMode, _ := cmd.Flags().GetString("latency")
+	if !stats.ValidLatencyMode(latencyMode) {
+		return 0, "", fmt.Errorf("invalid --latency %q, expected corrected, uncorrected or both", latencyMode)
+	}
+	return rate, latencyMode, nil
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"generator/load/src/http"
+
+	"github.com/spf13/cobra"
+)
+
+// AddTransportFlags registers the flags configuring the connections of the HTTP commands.
+func AddTransportFlags(cmd *cobra.Command) {
+	var http2 bool
+	var h2c bool
+	var http1Only bool
+	var cacert string
+	var cert string
+	var key string
+	var insecure bool
+	var serverName string
+	var minVersion string
+	var ciphers string
+
+	cmd.Flags().BoolVar(&http2, "http2", false, "Only use HTTP/2, negotiated over TLS, requests fail when the server doesn't offer it")
+	cmd.Flags().BoolVar(&h2c, "h2c", false, "Use HTTP/2 over plaintext (prior knowledge) for http:// destinations")
+	cmd.Flags().BoolVar(&http1Only, "http1-only", false, "Only use HTTP/1.1, even when the server offers HTTP/2")
+	cmd.MarkFlagsMutuallyExclusive("http2", "h2c", "http1-only")
+	cmd.Flags().StringVar(&cacert, "cacert", "", "PEM file of the CA certificates to verify the server with, instead of the system ones")
+	cmd.Flags().StringVar(&cert, "cert", "", "PEM file of the client certificate, with --key")
+	cmd.Flags().StringVar(&key, "key", "", "PEM file of the private key of the client certificate")
+	cmd.MarkFlagsRequiredTogether("cert", "key")
+	cmd.Flags().BoolVar(&insecure, "insecure-skip-verify", false, "Don't verify the server certificate, for self-signed test servers")
+	cmd.Flags().StringVar(&serverName, "server-name", "", "Server name sent with SNI and verified in the server certificate, the destination host by default")
+	cmd.Flags().StringVar(&minVersion, "tls-min-version", "1.2", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	cmd.Flags().StringVar(&ciphers, "tls-ciphers", "", "Cipher suites offered up to TLS 1.2, comma separated IANA names such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, Go's defaults when empty")
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TlsConfig reads the TLS configuration of the client from the flags. Sessions are cached, so new
+// connections can resume them.
+func TlsConfig(cmd *cobra.Command) (*tls.Config, error) {
+	cacert, _ := cmd.Flags().GetString("cacert")
+	cert, _ := cmd.Flags().GetString("cert")
+	key, _ := cmd.Flags().GetString("key")
+	insecure, _ := cmd.Flags().GetBool("insecure-skip-verify")
+	serverName, _ := cmd.Flags().GetString("server-name")
+	minVersion, _ := cmd.Flags().GetString("tls-min-version")
+	ciphers, _ := cmd.Flags().GetString("tls-ciphers")
+
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: insecure,
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid --tls-min-version %q, expected 1.0, 1.1, 1.2 or 1.3", minVersion)
+	}
+	config.MinVersion = version
+	if cacert != "" {
+		pem, err := os.ReadFile(cacert)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read --cacert: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid --cacert %q, no PEM certificate found", cacert)
+		}
+	}
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	if ciphers != "" {
+		suites := make(map[string]*tls.CipherSuite)
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[suite.Name] = suite
+		}
+		for _, name := range strings.Split(ciphers, ",") {
+			suite, ok := suites[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("invalid --tls-ciphers, unknown cipher suite %q", name)
+			}
+			if suite.SupportedVersions[0] == tls.VersionTLS13 {
+				return nil, fmt.Errorf("invalid --tls-ciphers, TLS 1.3 cipher suite %s can't be configured", suite.Name)
+			}
+			config.CipherSuites = append(config.CipherSuites, suite.ID)
+		}
+	}
+	return config, nil
+}
+
+// HttpVersion reads the HTTP versions the client may use from the flags, checked against the scheme
+// of the destination.
+func HttpVersion(cmd *cobra.Command, destination string) (string, error) {
+	version := http.VersionAuto
+	if http2, _ := cmd.Flags().GetBool("http2"); http2 {
+		version = http.VersionHttp2
+	} else if h2c, _ := cmd.Flags().GetBool("h2c"); h2c {
+		version = http.VersionH2c
+	} else if http1Only, _ := cmd.Flags().GetBool("http1-only"); http1Only {
+		version = http.VersionHttp1
+	}
+	target, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid --destination %q: %w", destination, err)
+	}
+	if version == http.VersionHttp2 && target.Scheme != "https" {
+		return "", fmt.Errorf("--http2 needs an https:// destination, use --h2c for HTTP/2 over plaintext")
+	}
+	if version == http.VersionH2c && target.Scheme != "http" {
+		return "", fmt.Errorf("--h2c needs an http:// destination, use --http2 for HTTP/2 over TLS")
+	}
+	return version, nil
+}
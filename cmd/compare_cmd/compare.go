@@ -0,0 +1,80 @@
+package compare_cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"generator/load/cmd/common"
+	"generator/load/src/report"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCompareCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare <baseline.json> <new.json>",
+		Short: "Compare two JSON run summaries and fail on performance regressions",
+		Args:  cobra.ExactArgs(2),
+		RunE:  compareExecute,
+	}
+
+	var throughputTolerance string
+	var latencyTolerance string
+	var latencyFloor time.Duration
+	var errorRateTolerance string
+
+	cmd.Flags().StringVar(&throughputTolerance, "throughput-tolerance", "5%", "Throughput drop allowed before it counts as a regression")
+	cmd.Flags().StringVar(&latencyTolerance, "latency-tolerance", "10%", "Increase of the mean latency or a percentile allowed before it counts as a regression")
+	cmd.Flags().DurationVar(&latencyFloor, "latency-floor", time.Millisecond, "Latency increases smaller than this are never regressions, however large relatively")
+	cmd.Flags().StringVar(&errorRateTolerance, "error-rate-tolerance", "0.5%", "Error rate increase allowed before it counts as a regression, in percentage points")
+
+	return cmd
+}
+
+func compareExecute(cmd *cobra.Command, args []string) error {
+	var tolerances report.Tolerances
+	var err error
+	for _, flag := range []struct {
+		name  string
+		value *float64
+	}{
+		{"throughput-tolerance", &tolerances.Throughput},
+		{"latency-tolerance", &tolerances.Latency},
+		{"error-rate-tolerance", &tolerances.ErrorRate},
+	} {
+		raw, _ := cmd.Flags().GetString(flag.name)
+		if *flag.value, err = parsePercent(raw); err != nil {
+			return fmt.Errorf("invalid --%s %q, expected a percentage such as 5%% or a fraction such as 0.05", flag.name, raw)
+		}
+	}
+	latencyFloor, _ := cmd.Flags().GetDuration("latency-floor")
+	tolerances.LatencyFloor = float64(latencyFloor) / float64(time.Millisecond)
+
+	baseline, err := report.ReadJsonFile(args[0])
+	if err != nil {
+		return err
+	}
+	current, err := report.ReadJsonFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	comparison := report.Compare(baseline, current, tolerances)
+	comparison.Print(os.Stdout)
+	if comparison.Regressed() {
+		cmd.SilenceUsage = true
+		return &common.ExitCodeError{Code: common.ExitRegression, Err: fmt.Errorf("%s regressed against %s", args[1], args[0])}
+	}
+	return nil
+}
+
+func parsePercent(raw string) (float64, error) {
+	if percent, found := strings.CutSuffix(raw, "%"); found {
+		value, err := strconv.ParseFloat(percent, 64)
+		return value / 100, err
+	}
+	return strconv.ParseFloat(raw, 64)
+}
@@ -1,9 +1,14 @@
 package grpc_cmd
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
+	"generator/load/cmd/common"
 	"generator/load/src/grpc"
+	"generator/load/src/stats"
+	"generator/load/src/util"
 
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
@@ -19,16 +24,26 @@ func NewGrpcCommand() *cobra.Command {
 	var proto_path string
 	var destination string
 	var req_num int
+	var conc int
 	var targetMethod string
 	var timeout int
 	var file_size int
+	var assertions []string
+	var max_retries int
 
 	grpcCmd.Flags().StringVar(&destination, "destination", "", "Destination Address")
 	grpcCmd.Flags().StringVar(&targetMethod, "tarm", "", "Target method to test on it")
 	grpcCmd.Flags().StringVar(&proto_path, "proto", "", "Path to the target proto file")
 	grpcCmd.Flags().IntVar(&req_num, "reqn", 10 , "Number of requests")
+	grpcCmd.Flags().IntVar(&conc, "conc", 0, "Number of calls in flight at most, 0 sends every call on its own worker")
 	grpcCmd.Flags().IntVar(&timeout, "timeout", 5, "Timeout for the requests")
+	grpcCmd.Flags().IntVar(&max_retries, "maxr", 0, "Maximum number of retries per failed unary call")
+	common.AddRetryFlags(grpcCmd)
+	common.AddScheduleFlags(grpcCmd, "Requests per second to dispatch at, 0 sends all requests at once")
 	grpcCmd.Flags().IntVar(&file_size, "size", 1024*1024, "File size for Client streaming load generation")
+	grpcCmd.Flags().StringArrayVar(&assertions, "assert", nil, "Check every call, repeatable: field:path[=value], status=OK, trailer:key[=value] or messages>=N for server streaming")
+
+	common.AddOutputFlags(grpcCmd)
 
 	return grpcCmd
 }
@@ -38,24 +53,62 @@ func grpcExecute(cmd *cobra.Command, args []string) error {
 
 	dest, _ := cmd.Flags().GetString("destination")
 	req_num, _ := cmd.Flags().GetInt("reqn")
+	conc, _ := cmd.Flags().GetInt("conc")
 	timeout, _ := cmd.Flags().GetInt("timeout")
 	file_size, _ := cmd.Flags().GetInt("size")
+	rate, latency_mode, err := common.Schedule(cmd)
+	if err != nil {
+		return err
+	}
+	if err := common.CheckOutputFlags(cmd); err != nil {
+		return err
+	}
 
-	method := getMethod(cmd)
+	method, err := getMethod(cmd)
+	if err != nil {
+		return err
+	}
 
-	if method == nil {
-		return nil
+	assert_texts, _ := cmd.Flags().GetStringArray("assert")
+	assertions, err := grpc.ParseAssertions(assert_texts, method)
+	if err != nil {
+		return err
 	}
 
-	grpc_req := grpc.GenerateGrpcReq(dest, method, req_num, timeout, file_size)
+	policy, err := common.RetryPolicy(cmd, time.Duration(timeout) * time.Second)
+	if err != nil {
+		return err
+	}
+
+	grpc_req := grpc.GenerateGrpcReq(dest, method, req_num, conc, timeout, file_size, rate, policy, assertions)
 
 	if grpc_req != nil {
-		grpc_req.GenerateLoad()
+		collector, err := common.NewCollector(cmd, stats.RunConfig{
+			Protocol: stats.ProtocolGrpc,
+			Mode: grpc_req.Mode(),
+			Target: dest,
+			Method: method.GetFullyQualifiedName(),
+			Requests: req_num,
+			Concurrency: util.Workers(conc, req_num),
+			Timeout: timeout,
+			MaxRetries: policy.MaxRetries,
+			Rate: rate,
+			LatencyMode: latency_mode,
+		})
+		if err != nil {
+			return err
+		}
+		collector.Start()
+		if err := grpc_req.GenerateLoad(collector); err != nil {
+			collector.Stop() // closes the sinks of the run that never started
+			return err
+		}
+		return common.Finish(cmd, collector)
 	}
 	return nil
 }
 
-func getMethod(cmd *cobra.Command)( m *desc.MethodDescriptor) {
+func getMethod(cmd *cobra.Command) (*desc.MethodDescriptor, error) {
 	// Get Flags 
 	filepath, _ := cmd.Flags().GetString("proto")
 	targetMethod, _ := cmd.Flags().GetString("tarm")
@@ -64,7 +117,7 @@ func getMethod(cmd *cobra.Command)( m *desc.MethodDescriptor) {
 	parser := protoparse.Parser{}
 	fds, err := parser.ParseFiles(filepath)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("cannot parse --proto: %w", err)
 	}
 
 	var method *desc.MethodDescriptor = nil
@@ -77,5 +130,8 @@ func getMethod(cmd *cobra.Command)( m *desc.MethodDescriptor) {
 		}
 	}
 
-	return method
+	if method == nil {
+		return nil, fmt.Errorf("no method %q in %s", targetMethod, filepath)
+	}
+	return method, nil
 }
\ No newline at end of file
@@ -1,7 +1,11 @@
 package http_cmd
 
 import (
+
+	"generator/load/cmd/common"
 	"generator/load/src/http"
+	"generator/load/src/stats"
+	"generator/load/src/util"
 
 	"github.com/spf13/cobra"
 )
@@ -17,18 +21,25 @@ func NewCsCommand() *cobra.Command {
 	var workerconc int
 	var timeout int
 	var maxretries int
+	var assertions []string
 	var size int
 
 	cmd.Flags().StringVar(&destination, "destination", "http://localhost:80/", "Full destination including protocol, address, port and url")
 	cmd.Flags().IntVar(&reqnum, "reqn", 1, "Number of requests to be done")
-	cmd.Flags().IntVar(&workerconc, "conc", 1, "Number of concurrent requests at the same time")
+	cmd.Flags().IntVar(&workerconc, "conc", 0, "Number of requests in flight at most, 0 sends every request on its own worker")
 	cmd.Flags().IntVar(&timeout, "timeout", 10, "Maximum number of seconds per request")
 	cmd.Flags().IntVar(&maxretries, "maxr", 3, "Maximum number of retries per failed request")
+	common.AddRetryFlags(cmd)
+	common.AddScheduleFlags(cmd, "Requests per second to dispatch at, 0 sends all requests at once")
+	common.AddTransportFlags(cmd)
+	cmd.Flags().StringArrayVar(&assertions, "assert", nil, "Check every response, repeatable: status=200,201, header:Name[=value], json:$.path[=value], body~=regex, body_size<=bytes or latency<=200ms")
 	cmd.Flags().IntVar(&size, "size", 1024*1024, "Size of the file to be uploaded.")
 
 	cmd.MarkFlagRequired("url")
 	cmd.MarkFlagRequired("address")
 
+	common.AddOutputFlags(cmd)
+
 	return cmd
 }
 
@@ -39,12 +50,55 @@ func csHttpExecute(cmd *cobra.Command, args []string) error {
 	workerconc, _ := cmd.Flags().GetInt("conc")
 	timeout, _ := cmd.Flags().GetInt("timeout")
 	maxretries, _ := cmd.Flags().GetInt("maxr")
+	rate, latencyMode, err := common.Schedule(cmd)
+	if err != nil {
+		return err
+	}
+	if err := common.CheckOutputFlags(cmd); err != nil {
+		return err
+	}
+	assertTexts, _ := cmd.Flags().GetStringArray("assert")
+	assertions, err := http.ParseAssertions(assertTexts, false)
+	if err != nil {
+		return err
+	}
+	policy, err := common.RetryPolicy(cmd, 0) // an upload has no time limit unless --try-timeout sets one
+	if err != nil {
+		return err
+	}
+	version, err := common.HttpVersion(cmd, destination)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := common.TlsConfig(cmd)
+	if err != nil {
+		return err
+	}
 	size, _ := cmd.Flags().GetInt("size")
-	println("Here is CS, Connected successfully")
-
-	h := http.GenerateHttpReq(destination, "", reqnum, workerconc, "CS", timeout, maxretries, size)
+	h := http.GenerateHttpReq(destination, "", reqnum, workerconc, "CS", timeout, policy, size, rate, assertions)
+	h.SetVersion(version)
+	h.SetTls(tlsConfig)
 
-	h.GenerateCsLoad()
-	
-	return nil
+	collector, err := common.NewCollector(cmd, stats.RunConfig{
+		Protocol: stats.ProtocolHttp,
+		Mode: stats.ModeClientStreaming,
+		Target: destination,
+		Method: "POST",
+		Requests: reqnum,
+		Concurrency: util.Workers(workerconc, reqnum),
+		Timeout: timeout,
+		MaxRetries: maxretries,
+		Rate: rate,
+		LatencyMode: latencyMode,
+		HttpVersion: version,
+	})
+	if err != nil {
+		return err
+	}
+	collector.Start()
+	if err := h.GenerateCsLoad(collector); err != nil {
+		collector.Stop() // closes the sinks of the run that never started
+		return err
+	}
+	return common.Finish(cmd, collector)
 }
\ No newline at end of file
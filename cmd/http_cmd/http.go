@@ -1,7 +1,13 @@
 package http_cmd
 
 import (
+	"strings"
+	"time"
+
+	"generator/load/cmd/common"
 	"generator/load/src/http"
+	"generator/load/src/stats"
+	"generator/load/src/util"
 
 	"github.com/spf13/cobra"
 )
@@ -22,37 +28,86 @@ func NewHttpCommand() *cobra.Command {
 	var httpmethod string
 	var timeout int
 	var maxretries int
+	var assertions []string
 
 	cmd.AddCommand(NewSseCommand())
 	cmd.AddCommand(NewCsCommand())
 
 	cmd.Flags().StringVar(&destination, "destination", "http://localhost:80/", "Full destination including protocol, address, port and url")
-	cmd.Flags().StringVar(&requestbody_path, "reqb_path", "", "Path to the file containing the request body for POST requests")
+	cmd.Flags().StringVar(&requestbody_path, "reqb_path", "", "Path to the file containing the request body for POST, PUT and PATCH requests")
 	cmd.Flags().IntVar(&reqnum, "reqn", 1, "Number of requests to be done")
-	cmd.Flags().IntVar(&workerconc, "conc", 1, "Number of concurrent requests at the same time")
-	cmd.Flags().StringVar(&httpmethod, "method", "POST", "HTTP method to use: GET, POST, PUT, DELETE")
+	cmd.Flags().IntVar(&workerconc, "conc", 0, "Number of requests in flight at most, 0 sends every request on its own worker")
+	cmd.Flags().StringVar(&httpmethod, "method", "POST", "HTTP method to use: GET, POST, PUT, PATCH or DELETE, the body is only sent with POST, PUT and PATCH")
 	cmd.Flags().IntVar(&timeout, "timeout", 5, "Maximum number of seconds per request")
 	cmd.Flags().IntVar(&maxretries, "maxr", 3, "Maximum number of retries per failed request")
+	common.AddRetryFlags(cmd)
+	common.AddScheduleFlags(cmd, "Requests per second to dispatch at, 0 sends all requests at once")
+	common.AddTransportFlags(cmd)
+	cmd.Flags().StringArrayVar(&assertions, "assert", nil, "Check every response, repeatable: status=200,201, header:Name[=value], json:$.path[=value], body~=regex, body_size<=bytes or latency<=200ms")
 
 	cmd.MarkFlagRequired("destination")
 
+	common.AddOutputFlags(cmd)
+
 	return cmd
 }
 
 
 func httpExecute(cmd *cobra.Command, args []string) error {
-	println("Here is HTTP, Connected successfully")
 	destination, _ := cmd.Flags().GetString("destination")
 	reqnum, _ := cmd.Flags().GetInt("reqn")
 	workerconc, _ := cmd.Flags().GetInt("conc")
 	timeout, _ := cmd.Flags().GetInt("timeout")
 	maxretries, _ := cmd.Flags().GetInt("maxr")
+	rate, latencyMode, err := common.Schedule(cmd)
+	if err != nil {
+		return err
+	}
+	if err := common.CheckOutputFlags(cmd); err != nil {
+		return err
+	}
+	assertTexts, _ := cmd.Flags().GetStringArray("assert")
+	assertions, err := http.ParseAssertions(assertTexts, false)
+	if err != nil {
+		return err
+	}
+	policy, err := common.RetryPolicy(cmd, time.Duration(timeout)*time.Second)
+	if err != nil {
+		return err
+	}
+	version, err := common.HttpVersion(cmd, destination)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := common.TlsConfig(cmd)
+	if err != nil {
+		return err
+	}
 	reqBody, _ := cmd.Flags().GetString("reqb_path")
 	reqMethod , _ := cmd.Flags().GetString("method")
+	reqMethod = strings.ToUpper(reqMethod)
 
-	h := http.GenerateHttpReq(destination, reqBody, reqnum, workerconc, reqMethod, timeout, maxretries, 0)
+	h := http.GenerateHttpReq(destination, reqBody, reqnum, workerconc, reqMethod, timeout, policy, 0, rate, assertions)
+	h.SetVersion(version)
+	h.SetTls(tlsConfig)
 
-	h.GenerateGenericLoad()
-	
-	return nil
+	collector, err := common.NewCollector(cmd, stats.RunConfig{
+		Protocol: stats.ProtocolHttp,
+		Mode: stats.ModeUnary,
+		Target: destination,
+		Method: reqMethod,
+		Requests: reqnum,
+		Concurrency: util.Workers(workerconc, reqnum),
+		Timeout: timeout,
+		MaxRetries: maxretries,
+		Rate: rate,
+		LatencyMode: latencyMode,
+		HttpVersion: version,
+	})
+	if err != nil {
+		return err
+	}
+	collector.Start()
+	h.GenerateGenericLoad(collector)
+	return common.Finish(cmd, collector)
 }
\ No newline at end of file
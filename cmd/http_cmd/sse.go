@@ -1,7 +1,13 @@
 package http_cmd
 
 import (
+	"fmt"
+	"time"
+
+	"generator/load/cmd/common"
 	"generator/load/src/http"
+	"generator/load/src/stats"
+	"generator/load/src/util"
 
 	"github.com/spf13/cobra"
 )
@@ -17,16 +23,27 @@ func NewSseCommand() *cobra.Command {
 	var workerconc int
 	var timeout int
 	var maxretries int
+	var assertions []string
+	var hold time.Duration
+	var reconnectDelay time.Duration
 
 	cmd.Flags().StringVar(&destination, "destination", "http://localhost:80/", "Full destination including protocol, address, port and url")
 	cmd.Flags().IntVar(&reqnum, "reqn", 1, "Number of requests to be done")
-	cmd.Flags().IntVar(&workerconc, "conc", 1, "Number of concurrent requests at the same time")
+	cmd.Flags().IntVar(&workerconc, "conc", 0, "Number of requests in flight at most, 0 sends every request on its own worker")
 	cmd.Flags().IntVar(&timeout, "timeout", 10, "Maximum number of seconds per request")
 	cmd.Flags().IntVar(&maxretries, "maxr", 3, "Maximum number of retries per failed request")
+	common.AddRetryFlags(cmd)
+	common.AddScheduleFlags(cmd, "Requests per second to dispatch at, 0 sends all requests at once")
+	common.AddTransportFlags(cmd)
+	cmd.Flags().StringArrayVar(&assertions, "assert", nil, "Check every stream on its response headers, repeatable: status=200, header:Name[=value] or latency<=200ms")
+	cmd.Flags().DurationVar(&hold, "hold", 0, "Hold every subscriber open for this long, reconnecting whenever its stream ends, 0 follows each stream once until it ends or times out")
+	cmd.Flags().DurationVar(&reconnectDelay, "reconnect-delay", 3*time.Second, "Wait before reconnecting a held subscriber, until the server sets another one with retry:, failed connections back off like retries")
 
 	cmd.MarkFlagRequired("url")
 	cmd.MarkFlagRequired("address")
 
+	common.AddOutputFlags(cmd)
+
 	return cmd
 }
 
@@ -37,12 +54,58 @@ func sseHttpExecute(cmd *cobra.Command, args []string) error {
 	workerconc, _ := cmd.Flags().GetInt("conc")
 	timeout, _ := cmd.Flags().GetInt("timeout")
 	maxretries, _ := cmd.Flags().GetInt("maxr")
-	println("Here is SSE, Connected successfully")
-
-	println(destination, reqnum, workerconc, timeout, maxretries)
-	h := http.GenerateHttpReq(destination, "", reqnum, workerconc, "SSE", timeout, maxretries, 0)
-
-	h.GenerateSseLoad()
+	rate, latencyMode, err := common.Schedule(cmd)
+	if err != nil {
+		return err
+	}
+	if err := common.CheckOutputFlags(cmd); err != nil {
+		return err
+	}
+	assertTexts, _ := cmd.Flags().GetStringArray("assert")
+	assertions, err := http.ParseAssertions(assertTexts, true)
+	if err != nil {
+		return err
+	}
+	policy, err := common.RetryPolicy(cmd, 0) // the client timeout caps the whole stream
+	if err != nil {
+		return err
+	}
+	version, err := common.HttpVersion(cmd, destination)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := common.TlsConfig(cmd)
+	if err != nil {
+		return err
+	}
+	hold, _ := cmd.Flags().GetDuration("hold")
+	reconnectDelay, _ := cmd.Flags().GetDuration("reconnect-delay")
+	if hold < 0 || reconnectDelay < 0 {
+		return fmt.Errorf("invalid --hold %s or --reconnect-delay %s, expected positive durations", hold, reconnectDelay)
+	}
+	h := http.GenerateHttpReq(destination, "", reqnum, workerconc, "SSE", timeout, policy, 0, rate, assertions)
+	h.SetVersion(version)
+	h.SetTls(tlsConfig)
+	h.SetHold(hold, reconnectDelay)
 
-	return nil
+	collector, err := common.NewCollector(cmd, stats.RunConfig{
+		Protocol: stats.ProtocolHttp,
+		Mode: stats.ModeSse,
+		Target: destination,
+		Method: "GET",
+		Requests: reqnum,
+		Concurrency: util.Workers(workerconc, reqnum),
+		Timeout: timeout,
+		MaxRetries: maxretries,
+		Rate: rate,
+		LatencyMode: latencyMode,
+		HttpVersion: version,
+		HoldSeconds: hold.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+	collector.Start()
+	h.GenerateSseLoad(collector)
+	return common.Finish(cmd, collector)
 }
\ No newline at end of file
@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"os"
+
+	. "generator/load/cmd/compare_cmd"
 	. "generator/load/cmd/grpc_cmd"
 	. "generator/load/cmd/http_cmd"
+	. "generator/load/cmd/ws_cmd"
+	"generator/load/src/logging"
 
 	"github.com/spf13/cobra"
 )
@@ -10,13 +15,35 @@ import (
 func NewRootCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "load-generator",
+		PersistentPreRunE: setupLogging,
 	}
 
+	var quiet bool
+	var verbose bool
+	var debug bool
+
+	cmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only log errors that stop the run")
+	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Also log the progress of the run")
+	cmd.PersistentFlags().BoolVar(&debug, "debug", false, "Also log a line per request")
+	cmd.MarkFlagsMutuallyExclusive("quiet", "verbose", "debug")
+
 	cmd.AddCommand(NewGrpcCommand())
 	cmd.AddCommand(NewHttpCommand())
+	cmd.AddCommand(NewWsCommand())
+	cmd.AddCommand(NewCompareCommand())
 
 	return cmd
 }
 
-
-
+// setupLogging sends log records to stderr, by default only warnings and sampled request failures.
+func setupLogging(cmd *cobra.Command, args []string) error {
+	level := logging.LevelDefault
+	if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+		level = logging.LevelQuiet
+	} else if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+		level = logging.LevelVerbose
+	} else if debug, _ := cmd.Flags().GetBool("debug"); debug {
+		level = logging.LevelDebug
+	}
+	return logging.Setup(level, os.Stderr)
+}
@@ -0,0 +1,98 @@
+package ws_cmd
+
+import (
+	"fmt"
+	"os"
+
+	"generator/load/cmd/common"
+	"generator/load/src/stats"
+	"generator/load/src/ws"
+
+	"github.com/spf13/cobra"
+)
+
+func NewWsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ws",
+		Short: "Open WebSocket connections that send messages and time the replies",
+		RunE:  wsExecute,
+	}
+
+	var destination string
+	var reqnum int
+	var timeout int
+	var message string
+	var messagePath string
+	var binary bool
+	var messages int
+	var messageRate float64
+	var correlate string
+
+	cmd.Flags().StringVar(&destination, "destination", "ws://localhost:80/", "Full destination including protocol (ws or wss), address, port and url")
+	cmd.Flags().IntVar(&reqnum, "reqn", 1, "Number of connections to open")
+	cmd.Flags().IntVar(&timeout, "timeout", 10, "Maximum number of seconds for the handshake, and to wait for replies after the last message")
+	cmd.Flags().StringVar(&message, "message", `{"id":"{{id}}","text":"hello"}`, "Message template, {{id}} (unique per message), {{conn}}, {{seq}} and {{time}} (unix nanoseconds) are replaced")
+	cmd.Flags().StringVar(&messagePath, "message_path", "", "Path to a file holding the message template, instead of --message")
+	cmd.Flags().BoolVar(&binary, "binary", false, "Send binary instead of text messages")
+	cmd.Flags().IntVar(&messages, "messages", 10, "Number of messages sent per connection")
+	cmd.Flags().Float64Var(&messageRate, "message-rate", 1, "Messages per second sent on each connection, 0 sends them back to back")
+	cmd.Flags().StringVar(&correlate, "correlate", ws.CorrelateEcho, "How replies are matched to messages for round trips: echo (the reply repeats the message) or the JSON path of the {{id}} in replies, e.g. $.id")
+
+	cmd.MarkFlagRequired("destination")
+
+	common.AddScheduleFlags(cmd, "Connections per second to open at, 0 opens all connections at once")
+	common.AddOutputFlags(cmd)
+
+	return cmd
+}
+
+func wsExecute(cmd *cobra.Command, args []string) error {
+	destination, _ := cmd.Flags().GetString("destination")
+	reqnum, _ := cmd.Flags().GetInt("reqn")
+	timeout, _ := cmd.Flags().GetInt("timeout")
+	rate, latencyMode, err := common.Schedule(cmd)
+	if err != nil {
+		return err
+	}
+	if err := common.CheckOutputFlags(cmd); err != nil {
+		return err
+	}
+	message, _ := cmd.Flags().GetString("message")
+	if messagePath, _ := cmd.Flags().GetString("message_path"); messagePath != "" {
+		content, err := os.ReadFile(messagePath)
+		if err != nil {
+			return fmt.Errorf("cannot read --message_path: %w", err)
+		}
+		message = string(content)
+	}
+	binary, _ := cmd.Flags().GetBool("binary")
+	messages, _ := cmd.Flags().GetInt("messages")
+	messageRate, _ := cmd.Flags().GetFloat64("message-rate")
+	if messages < 0 || messageRate < 0 {
+		return fmt.Errorf("invalid --messages %d or --message-rate %g, expected positive values", messages, messageRate)
+	}
+	correlate, _ := cmd.Flags().GetString("correlate")
+	if !ws.ValidCorrelation(correlate) {
+		return fmt.Errorf("invalid --correlate %q, expected echo or a JSON path such as $.id", correlate)
+	}
+
+	w := ws.GenerateWsReq(destination, reqnum, timeout, rate, message, binary, messages, messageRate, correlate)
+
+	collector, err := common.NewCollector(cmd, stats.RunConfig{
+		Protocol:    stats.ProtocolWebsocket,
+		Mode:        stats.ModeBidiStreaming,
+		Target:      destination,
+		Method:      "GET",
+		Requests:    reqnum,
+		Concurrency: reqnum,
+		Timeout:     timeout,
+		Rate:        rate,
+		LatencyMode: latencyMode,
+	})
+	if err != nil {
+		return err
+	}
+	collector.Start()
+	w.GenerateWsLoad(collector)
+	return common.Finish(cmd, collector)
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"errors"
+	"os"
+
 	"generator/load/cmd"
+	"generator/load/cmd/common"
 )
 
 func main(){
 	rootCmd := cmd.NewRootCommand()
  	if err := rootCmd.Execute(); err != nil {
-        panic(err)
+		var exitErr *common.ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+		os.Exit(common.ExitError)
     }
 }
\ No newline at end of file
@@ -0,0 +1,235 @@
+package assert
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operators of an assertion expression, matched in order so two character operators win over their prefix.
+const (
+	OpExists   = "" // no operator, the subject must be present
+	OpNotEqual = "!="
+	OpContains = "*="
+	OpMatches  = "~=" // regular expression
+	OpAtMost   = "<="
+	OpAtLeast  = ">="
+	OpEqual    = "="
+	OpBelow    = "<"
+	OpAbove    = ">"
+)
+
+var operators = []string{OpNotEqual, OpContains, OpMatches, OpAtMost, OpAtLeast, OpEqual, OpBelow, OpAbove}
+
+// Expression is a parsed "<subject>[:<key>][<operator><value>]" assertion, e.g. "header:Content-Type",
+// "json:$.status=ok" or "latency<=200ms".
+type Expression struct {
+	Text     string
+	Subject  string
+	Key      string
+	Operator string
+	Value    string
+	pattern  *regexp.Regexp // compiled Value of OpMatches
+}
+
+func Parse(text string) (Expression, error) {
+	e := Expression{Text: text}
+	left := text
+	if i, operator := findOperator(text); i >= 0 {
+		left, e.Operator, e.Value = text[:i], operator, text[i+len(operator):]
+	}
+	e.Subject, e.Key, _ = strings.Cut(strings.TrimSpace(left), ":")
+	e.Value = strings.TrimSpace(e.Value)
+	if e.Subject == "" {
+		return e, fmt.Errorf("invalid assertion %q, expected <subject>[:<key>][<operator><value>]", text)
+	}
+	if e.Operator == OpMatches {
+		pattern, err := regexp.Compile(e.Value)
+		if err != nil {
+			return e, fmt.Errorf("invalid assertion %q: %w", text, err)
+		}
+		e.pattern = pattern
+	}
+	return e, nil
+}
+
+// findOperator returns the position and the operator of the leftmost operator in text, -1 without one.
+func findOperator(text string) (int, string) {
+	for i := 0; i < len(text); i++ {
+		for _, operator := range operators {
+			if strings.HasPrefix(text[i:], operator) {
+				return i, operator
+			}
+		}
+	}
+	return -1, OpExists
+}
+
+// CompareText checks a textual value with the equality, containment or regular expression operators.
+func (e Expression) CompareText(actual string) bool {
+	switch e.Operator {
+	case OpExists:
+		return true
+	case OpEqual:
+		return actual == e.Value
+	case OpNotEqual:
+		return actual != e.Value
+	case OpContains:
+		return strings.Contains(actual, e.Value)
+	case OpMatches:
+		return e.pattern.MatchString(actual)
+	}
+	return false
+}
+
+// CompareNumber checks a number against the limit parsed from Value by ParseLimit.
+func (e Expression) CompareNumber(actual float64, limit float64) bool {
+	switch e.Operator {
+	case OpEqual:
+		return actual == limit
+	case OpNotEqual:
+		return actual != limit
+	case OpAtMost:
+		return actual <= limit
+	case OpAtLeast:
+		return actual >= limit
+	case OpBelow:
+		return actual < limit
+	case OpAbove:
+		return actual > limit
+	}
+	return false
+}
+
+// Numeric reports whether the operator compares numbers.
+func (e Expression) Numeric() bool {
+	switch e.Operator {
+	case OpAtMost, OpAtLeast, OpBelow, OpAbove, OpEqual, OpNotEqual:
+		return true
+	}
+	return false
+}
+
+// NumericOnly reports whether the operator only compares numbers, unlike = and != that compare text too.
+func (e Expression) NumericOnly() bool {
+	switch e.Operator {
+	case OpAtMost, OpAtLeast, OpBelow, OpAbove:
+		return true
+	}
+	return false
+}
+
+// List splits the comma separated Value of a <subject>=<values> or <subject>!=<values> expression.
+func (e Expression) List() ([]string, error) {
+	if e.Operator != OpEqual && e.Operator != OpNotEqual {
+		return nil, fmt.Errorf("invalid assertion %q, expected %s=<codes> or %s!=<codes>", e.Text, e.Subject, e.Subject)
+	}
+	values := strings.Split(e.Value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values, nil
+}
+
+// Listed reports whether a value passes a List expression, given whether it is one of the values.
+func (e Expression) Listed(found bool) bool {
+	return found == (e.Operator == OpEqual)
+}
+
+// ParseLimit parses Value as a number, or as a duration in seconds when duration is set.
+func (e Expression) ParseLimit(duration bool) (float64, error) {
+	if duration {
+		d, err := time.ParseDuration(e.Value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid assertion %q, %q is not a duration", e.Text, e.Value)
+		}
+		return d.Seconds(), nil
+	}
+	limit, err := strconv.ParseFloat(e.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid assertion %q, %q is not a number", e.Text, e.Value)
+	}
+	return limit, nil
+}
+
+// ParseCode parses a status code given by number, or by one of names indexed by code, compared
+// ignoring case and underscores so NOT_FOUND finds NotFound.
+func ParseCode(text string, names []string) (int, error) {
+	if number, err := strconv.ParseUint(text, 10, 31); err == nil {
+		return int(number), nil
+	}
+	for code, name := range names {
+		if strings.EqualFold(strings.ReplaceAll(text, "_", ""), name) {
+			return code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status code %q", text)
+}
+
+// Number converts a decoded JSON value to a number, numeric strings included since the proto JSON
+// mapping writes 64 bit integers as strings.
+func Number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// Lookup finds the value at path in a decoded JSON document. Paths are dot separated field names
+// with optional [index] array accesses and an optional leading "$.", e.g. "$.items[0].id".
+func Lookup(document any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	value := document
+	if path == "" {
+		return value, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[name]; !ok {
+				return nil, false
+			}
+		}
+		for rest != "" {
+			index, after, found := strings.Cut(rest, "]")
+			if !found {
+				return nil, false
+			}
+			array, ok := value.([]any)
+			i, err := strconv.Atoi(index)
+			if !ok || err != nil || i < 0 || i >= len(array) {
+				return nil, false
+			}
+			value = array[i]
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return value, true
+}
+
+// Format renders a decoded JSON value the way it is written in assertions: strings unquoted,
+// numbers and booleans as in JSON, objects and arrays as compact JSON.
+func Format(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
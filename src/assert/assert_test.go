@@ -0,0 +1,263 @@
+package assert
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Expression
+		wantErr bool
+	}{
+		{"header:Content-Type", Expression{Subject: "header", Key: "Content-Type", Operator: OpExists}, false},
+		{"status=200,201", Expression{Subject: "status", Operator: OpEqual, Value: "200,201"}, false},
+		{"status!=500", Expression{Subject: "status", Operator: OpNotEqual, Value: "500"}, false},
+		{"json:$.status = ok", Expression{Subject: "json", Key: "$.status", Operator: OpEqual, Value: "ok"}, false},
+		{"latency<=200ms", Expression{Subject: "latency", Operator: OpAtMost, Value: "200ms"}, false},
+		{"body_size>=10", Expression{Subject: "body_size", Operator: OpAtLeast, Value: "10"}, false},
+		{"messages<3", Expression{Subject: "messages", Operator: OpBelow, Value: "3"}, false},
+		{"messages>3", Expression{Subject: "messages", Operator: OpAbove, Value: "3"}, false},
+		{"body*=hello", Expression{Subject: "body", Operator: OpContains, Value: "hello"}, false},
+		{"body~=^ok$", Expression{Subject: "body", Operator: OpMatches, Value: "^ok$"}, false},
+		{"json:$.a=b=c", Expression{Subject: "json", Key: "$.a", Operator: OpEqual, Value: "b=c"}, false},
+		{"=200", Expression{}, true},
+		{"body~=(", Expression{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got.pattern = nil
+			tt.want.Text = tt.text
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareText(t *testing.T) {
+	tests := []struct {
+		text   string
+		actual string
+		want   bool
+	}{
+		{"header:X", "anything", true},
+		{"body=ok", "ok", true},
+		{"body=ok", "okay", false},
+		{"body!=ok", "okay", true},
+		{"body*=ka", "okay", true},
+		{"body*=ko", "okay", false},
+		{"body~=^o.a", "okay", true},
+		{"body~=^a", "okay", false},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.CompareText(tt.actual); got != tt.want {
+			t.Errorf("%s CompareText(%q) = %v, want %v", tt.text, tt.actual, got, tt.want)
+		}
+	}
+}
+
+func TestCompareNumber(t *testing.T) {
+	tests := []struct {
+		text   string
+		actual float64
+		want   bool
+	}{
+		{"n=3", 3, true},
+		{"n!=3", 3, false},
+		{"n<=3", 3, true},
+		{"n<3", 3, false},
+		{"n>=3", 4, true},
+		{"n>3", 3, false},
+		{"n*=3", 3, false},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.CompareNumber(tt.actual, 3); got != tt.want {
+			t.Errorf("%s CompareNumber(%g) = %v, want %v", tt.text, tt.actual, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		text     string
+		duration bool
+		want     float64
+		wantErr  bool
+	}{
+		{"latency<=200ms", true, 0.2, false},
+		{"latency<=1.5s", true, 1.5, false},
+		{"latency<=200", true, 0, true},
+		{"body_size<=1024", false, 1024, false},
+		{"body_size<=1k", false, 0, true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := e.ParseLimit(tt.duration)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s ParseLimit(%v) = %g, %v, want %g, error %v", tt.text, tt.duration, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNumericOnly(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"json:$.count<=3", true},
+		{"json:$.count>=3", true},
+		{"json:$.count<3", true},
+		{"json:$.count>3", true},
+		{"json:$.count=3", false},
+		{"json:$.count!=3", false},
+		{"body*=ok", false},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.NumericOnly(); got != tt.want {
+			t.Errorf("%s NumericOnly() = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []string
+		listed  bool // whether a value in the list passes
+		wantErr bool
+	}{
+		{"status=200", []string{"200"}, true, false},
+		{"status=200, 2xx ,404", []string{"200", "2xx", "404"}, true, false},
+		{"status!=500,503", []string{"500", "503"}, false, false},
+		{"status<=299", nil, false, true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := e.List()
+		if (err != nil) != tt.wantErr || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s List() = %q, %v, want %q, error %v", tt.text, got, err, tt.want, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if e.Listed(true) != tt.listed || e.Listed(false) == tt.listed {
+			t.Errorf("%s Listed(true) = %v, Listed(false) = %v, want %v, %v", tt.text, e.Listed(true), e.Listed(false), tt.listed, !tt.listed)
+		}
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	names := []string{"OK", "Canceled", "NotFound"}
+	tests := []struct {
+		text    string
+		names   []string
+		want    int
+		wantErr bool
+	}{
+		{"404", nil, 404, false},
+		{"0", names, 0, false},
+		{"NOT_FOUND", names, 2, false},
+		{"notfound", names, 2, false},
+		{"canceled", names, 1, false},
+		{"NotFound", nil, 0, true},
+		{"Unknown", names, 0, true},
+		{"-1", names, 0, true},
+		{"", names, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCode(tt.text, tt.names)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCode(%q) = %d, %v, want %d, error %v", tt.text, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		value any
+		want  float64
+		ok    bool
+	}{
+		{float64(3.5), 3.5, true},
+		{"9007199254740993", 9007199254740993, true},
+		{"-2", -2, true},
+		{"ok", 0, false},
+		{true, 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := Number(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Number(%#v) = %g, %v, want %g, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var document any
+	err := json.Unmarshal([]byte(`{"status":"ok","count":3,"data":{"items":[{"id":"a"},{"id":"b","tags":["x","y"]}]},"grid":[[1,2],[3,4]],"none":null}`), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  string
+		want  string
+		found bool
+	}{
+		{"$.status", "ok", true},
+		{"status", "ok", true},
+		{"$.count", "3", true},
+		{"$.data.items[1].id", "b", true},
+		{"data.items[1].tags[0]", "x", true},
+		{"$.grid[1][0]", "3", true},
+		{"$.data.items[0]", `{"id":"a"}`, true},
+		{"$.none", "null", true},
+		{"$", "", true},
+		{"$.missing", "", false},
+		{"$.data.items[2].id", "", false},
+		{"$.data.items[-1]", "", false},
+		{"$.data.items[x]", "", false},
+		{"$.data.items[0", "", false},
+		{"$.status.inner", "", false},
+		{"$.count[0]", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, found := Lookup(document, tt.path)
+			if found != tt.found {
+				t.Fatalf("Lookup(%q) found = %v, want %v", tt.path, found, tt.found)
+			}
+			if found && tt.path != "$" && Format(value) != tt.want {
+				t.Errorf("Lookup(%q) = %s, want %s", tt.path, Format(value), tt.want)
+			}
+		})
+	}
+}
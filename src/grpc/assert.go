@@ -0,0 +1,217 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"generator/load/src/assert"
+	"generator/load/src/stats"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Assertion is a check every call of a run must pass, parsed from an --assert expression:
+//
+//	field:<path>[<op><value>]     the response has a value at path, e.g. items[0].id, and it matches
+//	status=OK or status=NOT_FOUND  the call ended with one of the listed status codes
+//	trailer:<key>[<op><value>]    the trailer is present, and its value matches
+//	messages>=<n>                 the number of streamed messages compares with n, server streaming only
+//
+// Field paths use the proto field names, every message of a server stream is checked.
+type Assertion struct {
+	assert.Expression
+	codes []codes.Code // accepted status codes
+	limit float64      // parsed limit of a numeric comparison
+}
+
+// ParseAssertions parses --assert expressions for calls of method.
+func ParseAssertions(texts []string, method *desc.MethodDescriptor) ([]Assertion, error) {
+	assertions := make([]Assertion, 0, len(texts))
+	for _, text := range texts {
+		expression, err := assert.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		a := Assertion{Expression: expression}
+		switch a.Subject {
+		case "field":
+			if a.Key == "" {
+				return nil, fmt.Errorf("invalid assertion %q, expected field:<path>", text)
+			}
+			if a.NumericOnly() {
+				if a.limit, err = a.ParseLimit(false); err != nil {
+					return nil, err
+				}
+			}
+		case "status":
+			names, err := a.List()
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				code, err := assert.ParseCode(name, code_names)
+				if err != nil {
+					return nil, fmt.Errorf("invalid assertion %q: %w", text, err)
+				}
+				a.codes = append(a.codes, codes.Code(code))
+			}
+		case "trailer":
+			if a.Key == "" || a.NumericOnly() {
+				return nil, fmt.Errorf("invalid assertion %q, expected trailer:<key>[=value]", text)
+			}
+		case "messages":
+			if !method.IsServerStreaming() {
+				return nil, fmt.Errorf("invalid assertion %q, %s is not server streaming", text, method.GetName())
+			}
+			if !a.Numeric() {
+				return nil, fmt.Errorf("invalid assertion %q, expected messages>=<n>", text)
+			}
+			if a.limit, err = a.ParseLimit(false); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid assertion %q, unknown subject %q, expected field, status, trailer or messages", text, a.Subject)
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions, nil
+}
+
+// code_names are the names of the gRPC status codes, indexed by code.
+var code_names = func() []string {
+	names := make([]string, codes.Unauthenticated+1)
+	for code := range names {
+		names[code] = codes.Code(code).String()
+	}
+	return names
+}()
+
+// checks_fields reports whether the assertions look into response messages, which then get decoded.
+func checks_fields(assertions []Assertion) bool {
+	for _, a := range assertions {
+		if a.Subject == "field" {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts_status reports whether a call ending with code counts as answered: every status when
+// there are status assertions, check_call then fails the ones they reject, OK without them.
+func (g *grpcReq) accepts_status(code codes.Code) bool {
+	for _, a := range g.assertions {
+		if a.Subject == "status" {
+			return true
+		}
+	}
+	return code == codes.OK
+}
+
+func (a *Assertion) check_status(code codes.Code) string {
+	matched := false
+	for _, c := range a.codes {
+		if c == code {
+			matched = true
+		}
+	}
+	if !a.Listed(matched) {
+		return "status " + code.String()
+	}
+	return ""
+}
+
+var json_marshaler = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// message_json encodes a response message in the proto JSON mapping, by way of its wire format
+// since protojson only takes messages of the current protobuf API.
+func message_json(m *dynamic.Message) ([]byte, error) {
+	b, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	message := dynamicpb.NewMessage(m.GetMessageDescriptor().UnwrapMessage())
+	if err := proto.Unmarshal(b, message); err != nil {
+		return nil, err
+	}
+	return json_marshaler.Marshal(message)
+}
+
+// check_message checks the field assertions against one response message, returning the first
+// failed assertion and what was found instead, nil when the message passes.
+func (g *grpcReq) check_message(m *dynamic.Message) (*Assertion, string) {
+	if !g.check_fields {
+		return nil, ""
+	}
+	var document any
+	b, err := message_json(m)
+	if err == nil {
+		err = json.Unmarshal(b, &document)
+	}
+	for i := range g.assertions {
+		a := &g.assertions[i]
+		if a.Subject != "field" {
+			continue
+		}
+		if err != nil {
+			return a, "undecodable message: " + err.Error()
+		}
+		value, ok := assert.Lookup(document, a.Key)
+		if !ok {
+			return a, "no such field"
+		}
+		if a.NumericOnly() {
+			number, isNumber := assert.Number(value)
+			if !isNumber || !a.CompareNumber(number, a.limit) {
+				return a, assert.Format(value)
+			}
+		} else if !a.CompareText(assert.Format(value)) {
+			return a, fmt.Sprintf("%q", assert.Format(value))
+		}
+	}
+	return nil, ""
+}
+
+// check_call checks the status, trailer and message count assertions once the call ended.
+func (g *grpcReq) check_call(code codes.Code, trailer metadata.MD, messages int) (*Assertion, string) {
+	for i := range g.assertions {
+		a := &g.assertions[i]
+		switch a.Subject {
+		case "status":
+			if got := a.check_status(code); got != "" {
+				return a, got
+			}
+		case "trailer":
+			values := trailer.Get(a.Key)
+			if len(values) == 0 {
+				return a, "no such trailer"
+			}
+			if value := strings.Join(values, ", "); !a.CompareText(value) {
+				return a, fmt.Sprintf("%q", value)
+			}
+		case "messages":
+			if !a.CompareNumber(float64(messages), a.limit) {
+				return a, fmt.Sprintf("%d messages", messages)
+			}
+		}
+	}
+	return nil, ""
+}
+
+// fail_assertion fails a call that got an answer, counting it as an assertion error.
+func fail_assertion(result *stats.Result, a *Assertion, got string, m *dynamic.Message) {
+	result.Successful = false
+	result.ErrorClass = stats.ErrorAssertion
+	result.Assertion = a.Text
+	result.Error = fmt.Sprintf("assertion %s failed, got %s", a.Text, got)
+	if m != nil {
+		body := m.String()
+		result.Body = body[:min(len(body), stats.BodySampleSize)]
+	}
+}
@@ -0,0 +1,210 @@
+package grpc
+
+import (
+	"testing"
+
+	"generator/load/src/assert"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const test_proto = `
+syntax = "proto3";
+package test;
+
+message Request { string user_id = 1; }
+message Item { string id = 1; }
+message Reply {
+  string status = 1;
+  int64 count = 2;
+  repeated Item items = 3;
+  double ratio = 4;
+}
+
+service Users {
+  rpc Get(Request) returns (Reply);
+  rpc Watch(Request) returns (stream Reply);
+}
+`
+
+// test_methods parses test_proto and returns its methods by name.
+func test_methods(t *testing.T) map[string]*desc.MethodDescriptor {
+	t.Helper()
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{"test.proto": test_proto})}
+	files, err := parser.ParseFiles("test.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := make(map[string]*desc.MethodDescriptor)
+	for _, m := range files[0].GetServices()[0].GetMethods() {
+		methods[m.GetName()] = m
+	}
+	return methods
+}
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    codes.Code
+		wantErr bool
+	}{
+		{"OK", codes.OK, false},
+		{"NOT_FOUND", codes.NotFound, false},
+		{"NotFound", codes.NotFound, false},
+		{"not_found", codes.NotFound, false},
+		{"RESOURCE_EXHAUSTED", codes.ResourceExhausted, false},
+		{"UNAUTHENTICATED", codes.Unauthenticated, false},
+		{"14", codes.Unavailable, false},
+		{"NOPE", 0, true},
+		{"-1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := assert.ParseCode(tt.name, code_names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCode(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+			if codes.Code(got) != tt.want {
+				t.Errorf("ParseCode(%q) = %v, want %v", tt.name, codes.Code(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAssertions(t *testing.T) {
+	methods := test_methods(t)
+	tests := []struct {
+		text    string
+		method  string
+		wantErr bool
+	}{
+		{"field:status", "Get", false},
+		{"field:items[0].id=a", "Get", false},
+		{"field:count>=3", "Get", false},
+		{"field:count>=many", "Get", true},
+		{"field=ok", "Get", true},
+		{"status=OK", "Get", false},
+		{"status=OK,NOT_FOUND", "Get", false},
+		{"status!=UNAVAILABLE", "Get", false},
+		{"status=MAYBE", "Get", true},
+		{"status>0", "Get", true},
+		{"trailer:x-request-id", "Get", false},
+		{"trailer:x-request-id=abc", "Get", false},
+		{"trailer=abc", "Get", true},
+		{"trailer:x-count>1", "Get", true},
+		{"messages>=3", "Watch", false},
+		{"messages>=3", "Get", true},
+		{"messages*=3", "Watch", true},
+		{"header:content-type", "Get", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text+" "+tt.method, func(t *testing.T) {
+			_, err := ParseAssertions([]string{tt.text}, methods[tt.method])
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAssertions(%q) on %s error = %v, want error %v", tt.text, tt.method, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// test_req builds a request of method checking the assertions.
+func test_req(t *testing.T, method *desc.MethodDescriptor, texts ...string) *grpcReq {
+	t.Helper()
+	assertions, err := ParseAssertions(texts, method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &grpcReq{method: method, assertions: assertions, check_fields: checks_fields(assertions)}
+}
+
+func TestAcceptsStatus(t *testing.T) {
+	get := test_methods(t)["Get"]
+	tests := []struct {
+		name       string
+		assertions []string
+		code       codes.Code
+		want       bool
+	}{
+		{"ok without assertions", nil, codes.OK, true},
+		{"error without assertions", nil, codes.Unavailable, false},
+		{"asserted status", []string{"status=NOT_FOUND"}, codes.NotFound, true},
+		{"status the assertion rejects", []string{"status=OK"}, codes.Unavailable, true},
+		{"field assertions only", []string{"field:status"}, codes.Unavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := test_req(t, get, tt.assertions...).accepts_status(tt.code); got != tt.want {
+				t.Errorf("accepts_status(%v) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMessage(t *testing.T) {
+	get := test_methods(t)["Get"]
+	reply := dynamic.NewMessage(get.GetOutputType())
+	reply.SetFieldByName("status", "ok")
+	reply.SetFieldByName("count", int64(3))
+	item := dynamic.NewMessage(get.GetOutputType().FindFieldByName("items").GetMessageType())
+	item.SetFieldByName("id", "a")
+	reply.AddRepeatedFieldByName("items", item)
+
+	tests := []struct {
+		text string
+		got  string // what check_message found instead, empty when the message passes
+	}{
+		{"field:status", ""},
+		{"field:status=ok", ""},
+		{"field:status!=ok", `"ok"`},
+		{"field:count>=3", ""},
+		{"field:count>3", "3"},
+		{"field:count=3", ""},
+		{"field:items[0].id=a", ""},
+		{"field:items[1].id", "no such field"},
+		{"field:ratio=0", ""}, // unset fields are decoded with their default
+		{"field:missing", "no such field"},
+		{"status=OK", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			failed, got := test_req(t, get, tt.text).check_message(reply)
+			if got != tt.got || (failed != nil) != (tt.got != "") {
+				t.Errorf("check_message(%q) = %v, %q, want %q", tt.text, failed, got, tt.got)
+			}
+		})
+	}
+}
+
+func TestCheckCall(t *testing.T) {
+	watch := test_methods(t)["Watch"]
+	trailer := metadata.Pairs("x-request-id", "abc")
+	tests := []struct {
+		text     string
+		code     codes.Code
+		messages int
+		got      string
+	}{
+		{"status=OK", codes.OK, 0, ""},
+		{"status=OK", codes.Unavailable, 0, "status Unavailable"},
+		{"status=NOT_FOUND,OK", codes.NotFound, 0, ""},
+		{"status!=UNAVAILABLE", codes.Unavailable, 0, "status Unavailable"},
+		{"trailer:x-request-id", codes.OK, 0, ""},
+		{"trailer:x-request-id=abc", codes.OK, 0, ""},
+		{"trailer:x-request-id=xyz", codes.OK, 0, `"abc"`},
+		{"trailer:x-missing", codes.OK, 0, "no such trailer"},
+		{"messages>=3", codes.OK, 3, ""},
+		{"messages>=3", codes.OK, 2, "2 messages"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			failed, got := test_req(t, watch, tt.text).check_call(tt.code, trailer, tt.messages)
+			if got != tt.got || (failed != nil) != (tt.got != "") {
+				t.Errorf("check_call(%q, %v, %d) = %v, %q, want %q", tt.text, tt.code, tt.messages, failed, got, tt.got)
+			}
+		})
+	}
+}
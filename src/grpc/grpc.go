@@ -5,15 +5,21 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"sync"
 	"time"
 
+	"generator/load/src/retry"
+	"generator/load/src/stats"
+	"generator/load/src/telemetry"
 	"generator/load/src/util"
 
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/dynamic"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/rand"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	dpb "google.golang.org/protobuf/types/descriptorpb"
 )
@@ -22,111 +28,72 @@ type grpcReq struct {
 	destination string
 	method *desc.MethodDescriptor
 	req_num int
+	conc int // calls in flight at most, 0 sends every call on its own worker.
 	timeout int
 	file_size int
-}
-
-
-type reqStat struct {
-	latency float32
-	successful bool
-	serverStream bool
-	events int
+	rate float64 // requests per second to dispatch at, 0 dispatches all requests at once.
+	assertions []Assertion // checks every call must pass, from --assert.
+	retry retry.Policy // when and how often failed unary calls are retried.
+	check_fields bool // whether response messages are decoded for field assertions.
 }
 
 /// API
 
-func GenerateGrpcReq(dest string, method *desc.MethodDescriptor, reqn int, timeout int, file_size int) *grpcReq {
+func GenerateGrpcReq(dest string, method *desc.MethodDescriptor, reqn int, conc int, timeout int, file_size int, rate float64, policy retry.Policy, assertions []Assertion) *grpcReq {
 	return &grpcReq{
 		destination: dest,
 		method: method,
 		req_num: reqn,
+		conc: conc,
 		timeout: timeout,
 		file_size: file_size,
+		rate: rate,
+		assertions: assertions,
+		retry: policy,
+		check_fields: checks_fields(assertions),
 	}
 }
 
-func (g *grpcReq) GenerateLoad() {
-	output := make(chan reqStat)
-	var wg sync.WaitGroup
-	var result_collector sync.WaitGroup
+// Mode names the streaming kind of the target method, as reported in the run summary.
+func (g *grpcReq) Mode() string {
+	if g.method.IsClientStreaming() {
+		return stats.ModeClientStreaming
+	} else if g.method.IsServerStreaming() {
+		return stats.ModeServerStreaming
+	}
+	return stats.ModeUnary
+}
 
+// GenerateLoad runs the calls of the run, it fails without sending any when the file to upload
+// can't be generated or the connection can't be set up.
+func (g *grpcReq) GenerateLoad(collector *stats.Collector) error {
 	var path string = ""
 	if g.method.IsClientStreaming() {
 		var err error = nil
 		path, err = util.GenerateFile("demo.txt", g.file_size)
 		if err != nil {
-			// println(err.Error())
-			return 
+			return fmt.Errorf("cannot generate the file to upload: %w", err)
 		}
+		defer os.Remove(path)
 	}
 
-	time_before := time.Now()
 	// Shared between requests
 	conn, err := grpc.Dial(g.destination, grpc.WithInsecure())
 	if err != nil {
-		return
+		return fmt.Errorf("cannot connect to %s: %w", g.destination, err)
 	}
 	defer conn.Close()
-	for i:= 0; i< g.req_num;i++ {
-		wg.Add(1)
+	schedule := util.NewSchedule(g.rate)
+	schedule.Dispatch(g.req_num, g.conc, func(worker int, i int, intended time.Time) {
 		if !g.method.IsClientStreaming() && !g.method.IsServerStreaming() {
-			go g.generate_one_generic_load(conn, &wg, output)
+			g.generate_one_generic_load(conn, collector, worker, i, intended)
 		} else if g.method.IsClientStreaming() && !g.method.IsServerStreaming() {
-			go g.generate_one_clients_load(conn, &wg, output, path)
+			g.generate_one_clients_load(conn, collector, worker, i, path, intended)
 		} else if g.method.IsServerStreaming() && !g.method.IsClientStreaming() {
-			go g.generate_one_servers_load(conn, &wg, output)
+			g.generate_one_servers_load(conn, collector, worker, i, intended)
 		}
-	}
-	
-	go func (ch <- chan reqStat, wg *sync.WaitGroup) {
-		wg.Add(1)
-		defer wg.Done()
-		var total_latency float32
-		var total_count int = 0
-		var total_events int = 0
-		var successful int = 0
-		
-		for {
-			select {
-			case val, ok := <-ch:
-				if !ok {
-					fmt.Println("\n\n\n############################################  Final Results  #########################################################\n\n\n")
-					fmt.Printf("Average Latency: %.3f Second\n", total_latency/float32(total_count))
-					fmt.Printf("Total Success percent: %.2f%%\n", float32(successful)/float32(total_count)*100)
-					fmt.Printf("Total number of requests: %d\n", total_count)
-					fmt.Printf("Average Events: %d\n", total_events/total_count)
-					return
-				}
-				fmt.Printf("Latency: %.3f\n", val.latency)
-				fmt.Println("Successful: ", val.successful)
-				if val.serverStream {
-						fmt.Printf("Events: %d\n",val.events)
-						total_events += val.events
-				}
-				total_latency += float32(val.latency)
-				total_count ++
-				if val.successful {
-					successful ++
-				}
-			}
-		}
-		
-	}(output, &result_collector)
-
-	wg.Wait()
-	close(output)
-	result_collector.Wait()
-
-	time_after := time.Now()
-	total_time_taken := time_after.Sub(time_before).Seconds()
-
-	fmt.Printf("Total time taken: %.4f Second\n", float32(total_time_taken))
-	fmt.Printf("Total throughput: %.4f Request/Second\n", float32(g.req_num)/float32(total_time_taken))
-
-	if g.method.IsClientStreaming() {
-		os.Remove(path)
-	}
+	})
+	return nil
 }
 
 /// Internal
@@ -141,9 +108,51 @@ func randString(n int) string {
     return string(b)
 }
 
+func message_size(m *dynamic.Message) int64 {
+	b, err := m.Marshal()
+	if err != nil {
+		return 0
+	}
+	return int64(len(b))
+}
 
-func (g *grpcReq) generate_one_generic_load(conn *grpc.ClientConn, wg *sync.WaitGroup, ch chan reqStat){
-	defer wg.Done()
+func record_failure(collector *stats.Collector, result *stats.Result, err error) {
+	result.End = time.Now()
+	result.Status = int(status.Code(err))
+	if result.ErrorClass == "" {
+		result.ErrorClass = stats.ClassifyGrpcError(err)
+	}
+	result.Error = err.Error()
+	collector.Record(*result)
+}
+
+// assert_call checks the assertions once a call got its answer, resp is checked when the call
+// succeeded and its messages weren't checked as they arrived.
+func (g *grpcReq) assert_call(result *stats.Result, code codes.Code, trailer metadata.MD, resp *dynamic.Message, messages int) {
+	if code == codes.OK && resp != nil {
+		if a, got := g.check_message(resp); a != nil {
+			fail_assertion(result, a, got, resp)
+			return
+		}
+	}
+	if a, got := g.check_call(code, trailer, messages); a != nil {
+		fail_assertion(result, a, got, nil)
+	}
+}
+
+// start_span starts the client span of one request and returns its context carrying the
+// traceparent metadata, traced only when OTLP trace export is on.
+func (g *grpcReq) start_span() (context.Context, trace.Span) {
+	ctx, span := telemetry.StartSpan(context.Background(), "lgen "+g.get_method_full_name(),
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", g.method.GetService().GetFullyQualifiedName()),
+		attribute.String("rpc.method", g.method.GetName()),
+		attribute.String("server.address", g.destination))
+	return telemetry.InjectGrpc(ctx), span
+}
+
+
+func (g *grpcReq) generate_one_generic_load(conn *grpc.ClientConn, collector *stats.Collector, worker int, request int, intended time.Time){
 	fullMethodName := fmt.Sprintf(
 		"/%s.%s/%s",
 		g.method.GetService().GetFile().GetPackage(),
@@ -157,32 +166,51 @@ func (g *grpcReq) generate_one_generic_load(conn *grpc.ClientConn, wg *sync.Wait
 			req.SetFieldByName(field.GetName(), randString(2)) // Random Generation, to be fixed
 		}
 	}
-	time_before := time.Now()
+	result := stats.Result{
+		Worker: worker,
+		Request: request,
+		Intended: intended,
+		Start: time.Now(),
+	}
+	collector.Dispatch()
+	ctx, span := g.start_span()
+	defer func() { telemetry.EndSpan(span, result, "rpc.grpc.status_code") }()
+	g.retry.Run(ctx, &result, func(ctx context.Context) {
+		g.generic_try(ctx, conn, fullMethodName, req, &result)
+	})
+	result.End = time.Now()
+	result.BytesOut = message_size(req) * int64(result.Attempts)
+	collector.Record(result)
+	return
+}
+
+
+// generic_try makes the unary call once, the outcome of the attempt is left in result.
+func (g *grpcReq) generic_try(ctx context.Context, conn *grpc.ClientConn, fullMethodName string, req *dynamic.Message, result *stats.Result) {
 	resp := dynamic.NewMessage(g.method.GetOutputType())
+	var trailer metadata.MD
 	err := grpc.Invoke(
-			context.Background(),
+			ctx,
 			fullMethodName,
 			req,
 			resp,
 			conn,
-			)	
-	time_after := time.Now()
-	if err != nil {
-		ch <- reqStat{}
+			grpc.Trailer(&trailer),
+			)
+	code := status.Code(err)
+	result.Status = int(code)
+	if err != nil && !g.accepts_status(code) {
+		result.ErrorClass = stats.ClassifyGrpcError(err)
+		result.Error = err.Error()
 		return
 	}
-
-	duration := time_after.Sub(time_before).Seconds()
-	ch <- reqStat{
-		latency: float32(duration),
-		successful: true,
-	}
-	return
+	result.BytesIn += message_size(resp)
+	result.Successful = true
+	g.assert_call(result, code, trailer, resp, 0)
 }
 
 
-func (g *grpcReq) generate_one_servers_load(conn *grpc.ClientConn, wg *sync.WaitGroup, ch chan reqStat){
-	defer wg.Done()
+func (g *grpcReq) generate_one_servers_load(conn *grpc.ClientConn, collector *stats.Collector, worker int, request int, intended time.Time){
 	fullMethodName := fmt.Sprintf(
 		"/%s.%s/%s",
 		g.method.GetService().GetFile().GetPackage(),
@@ -197,9 +225,19 @@ func (g *grpcReq) generate_one_servers_load(conn *grpc.ClientConn, wg *sync.Wait
 		}
 	}
 
-	time_before := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(g.timeout) * time.Second)
+	result := stats.Result{
+		Worker: worker,
+		Request: request,
+		Intended: intended,
+		Start: time.Now(),
+		BytesOut: message_size(req),
+	}
+	collector.Dispatch()
+	span_ctx, span := g.start_span()
+	defer func() { telemetry.EndSpan(span, result, "rpc.grpc.status_code") }()
+	ctx, cancel := context.WithTimeout(span_ctx, time.Duration(g.timeout) * time.Second)
 	defer cancel()
+	timing := stats.NewStreamTiming()
 	stream, err := conn.NewStream(
         ctx,
         &grpc.StreamDesc{
@@ -209,19 +247,27 @@ func (g *grpcReq) generate_one_servers_load(conn *grpc.ClientConn, wg *sync.Wait
         fullMethodName,
     )
 	if err != nil {
-		ch <- reqStat{}
+		record_failure(collector, &result, err)
 		return
 	}
 
 	if err := stream.SendMsg(req); err != nil {
-        ch <- reqStat{}
+        record_failure(collector, &result, err)
         return
     }
 	if err := stream.CloseSend(); err != nil {
-        ch <- reqStat{}
+        record_failure(collector, &result, err)
         return
     }
+	if _, err := stream.Header(); err == nil {
+		timing.Headers()
+		result.Stream = timing
+	}
 	var events int = 0 // number of recieved events from the reciever
+	var code codes.Code = codes.OK
+	var failed *Assertion = nil // first field assertion a streamed message failed
+	var failed_got string
+	var failed_message *dynamic.Message
 	resp := dynamic.NewMessage(g.method.GetOutputType())
 	for {
         err := stream.RecvMsg(resp)
@@ -229,33 +275,58 @@ func (g *grpcReq) generate_one_servers_load(conn *grpc.ClientConn, wg *sync.Wait
             break
         }
         if err != nil {
-			st, _ := status.FromError(err)
-			if st.Code() == 4 { // Deadline exceeded
+			if status.Code(err) == codes.DeadlineExceeded {
+				break
+			}
+			if g.accepts_status(status.Code(err)) {
+				code = status.Code(err)
 				break
 			}
-            ch <- reqStat{}
+            result.Events = events
+            timing.Finish()
+            record_failure(collector, &result, err)
             return
         }
+		timing.Event()
 		events++
+		result.BytesIn += message_size(resp)
+		if failed == nil {
+			if failed, failed_got = g.check_message(resp); failed != nil {
+				failed_message = dynamic.NewMessage(g.method.GetOutputType())
+				failed_message.Merge(resp)
+			}
+		}
     }
-	time_after := time.Now()
-
-	duration := time_after.Sub(time_before).Seconds()
-	ch <- reqStat{
-		latency: float32(duration),
-		successful: true,
-		events: events,
-		serverStream: true,
+	result.End = time.Now()
+	timing.Finish()
+
+	result.Status = int(code)
+	result.Events = events
+	result.Successful = true
+	if failed != nil {
+		fail_assertion(&result, failed, failed_got, failed_message)
+	} else {
+		g.assert_call(&result, code, stream.Trailer(), nil, events)
 	}
+	collector.Record(result)
 	return
 }
 
 
-func (g *grpcReq) generate_one_clients_load(conn *grpc.ClientConn, wg *sync.WaitGroup, ch chan reqStat, file_path string) {
-	defer wg.Done()
+func (g *grpcReq) generate_one_clients_load(conn *grpc.ClientConn, collector *stats.Collector, worker int, request int, file_path string, intended time.Time) {
+
+	result := stats.Result{
+		Worker: worker,
+		Request: request,
+		Intended: intended,
+		Start: time.Now(),
+	}
+	collector.Dispatch()
+	ctx, span := g.start_span()
+	defer func() { telemetry.EndSpan(span, result, "rpc.grpc.status_code") }()
 
 	stream, err := conn.NewStream(
-		context.Background(),
+		ctx,
 		&grpc.StreamDesc{
 			ClientStreams: true,
 			ServerStreams: false,
@@ -263,7 +334,7 @@ func (g *grpcReq) generate_one_clients_load(conn *grpc.ClientConn, wg *sync.Wait
 		g.get_method_full_name(),
 	)
 	if err != nil {
-		ch <- reqStat{}
+		record_failure(collector, &result, err)
 		return
 	}
 
@@ -280,13 +351,14 @@ func (g *grpcReq) generate_one_clients_load(conn *grpc.ClientConn, wg *sync.Wait
 
 	file, err := os.Open(file_path)
 	if err != nil {
-		ch <- reqStat{}
+		result.ErrorClass = stats.ErrorRequest
+		record_failure(collector, &result, err)
 		return
 	}
 	defer file.Close()
 
 	buf := make([]byte, 1024*1024)
-	time_before := time.Now()
+	result.Start = time.Now()
 
 	for {
 		n, err := file.Read(buf)
@@ -294,7 +366,8 @@ func (g *grpcReq) generate_one_clients_load(conn *grpc.ClientConn, wg *sync.Wait
 			break
 		}
 		if err != nil {
-			ch <- reqStat{}
+			result.ErrorClass = stats.ErrorRequest
+			record_failure(collector, &result, err)
 			return
 		}
 
@@ -306,33 +379,34 @@ func (g *grpcReq) generate_one_clients_load(conn *grpc.ClientConn, wg *sync.Wait
 		msg.SetFieldByName(field_target.GetName(), chunkCopy)
 
 		if err := stream.SendMsg(msg); err != nil {
-			ch <- reqStat{}
+			record_failure(collector, &result, err)
 			return
 		}
+		result.BytesOut += int64(n)
 	}
 
 	if err := stream.CloseSend(); err != nil {
-		ch <- reqStat{}
-		return 
+		record_failure(collector, &result, err)
+		return
 	}
 
 	resp := dynamic.NewMessage(g.method.GetOutputType())
-	if err := stream.RecvMsg(resp); err != nil {
-		ch <- reqStat{}
+	err = stream.RecvMsg(resp)
+	code := status.Code(err)
+	if err != nil && !g.accepts_status(code) {
+		record_failure(collector, &result, err)
 		return
 	}
 
-	time_after := time.Now()
-
-	latency := time_after.Sub(time_before).Seconds()
+	result.End = time.Now()
 
-	ch <- reqStat{
-		latency: float32(latency),
-		successful: true,
-		serverStream: false,
-	}
+	result.Status = int(code)
+	result.BytesIn = message_size(resp)
+	result.Successful = true
+	g.assert_call(&result, code, stream.Trailer(), resp, 0)
+	collector.Record(result)
 
-	return 
+	return
 }
 
 
@@ -343,4 +417,4 @@ func (g *grpcReq) get_method_full_name() string {
 		g.method.GetService().GetName(),
 		g.method.GetName(),
 	)
-}
\ No newline at end of file
+}
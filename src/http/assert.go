@@ -0,0 +1,178 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"generator/load/src/assert"
+)
+
+// Assertion is a check every response of a run must pass, parsed from an --assert expression:
+//
+//	status=200,201 or status=2xx   the status is one of the listed codes or classes
+//	header:Name[<op><value>]       the header is present, and its value matches
+//	json:<path>[<op><value>]       the JSON body has a value at path, e.g. $.data.items[0].id, and it matches
+//	body<op><value>                the body equals (=), contains (*=) or matches (~=) the value
+//	body_size<=<bytes>             the body size compares with the number of bytes
+//	latency<=<duration>            the response arrived within the duration, e.g. 200ms
+//
+// Text operators are = != *= (contains) and ~= (regular expression), numeric ones = != < <= > >=.
+type Assertion struct {
+	assert.Expression
+	statuses []string // accepted status codes and classes, e.g. "404" or "2xx"
+	limit    float64  // parsed limit of a numeric comparison, seconds for latency
+}
+
+// ParseAssertions parses --assert expressions, stream rejects the assertions on the response body
+// that a stream of events can't be checked against.
+func ParseAssertions(texts []string, stream bool) ([]Assertion, error) {
+	assertions := make([]Assertion, 0, len(texts))
+	for _, text := range texts {
+		expression, err := assert.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		a := Assertion{Expression: expression}
+		switch a.Subject {
+		case "status":
+			statuses, err := a.List()
+			if err != nil {
+				return nil, err
+			}
+			for _, status := range statuses {
+				status = strings.ToLower(status)
+				if _, err := assert.ParseCode(status, nil); err != nil && !(len(status) == 3 && strings.HasSuffix(status, "xx")) {
+					return nil, fmt.Errorf("invalid assertion %q, %q is neither a status code nor a class like 2xx", text, status)
+				}
+				a.statuses = append(a.statuses, status)
+			}
+		case "header":
+			if a.Key == "" {
+				return nil, fmt.Errorf("invalid assertion %q, expected header:<name>", text)
+			}
+		case "json", "body", "body_size":
+			if stream {
+				return nil, fmt.Errorf("invalid assertion %q, the body of an event stream can't be asserted", text)
+			}
+			if a.Subject == "json" && a.Key == "" {
+				return nil, fmt.Errorf("invalid assertion %q, expected json:<path>", text)
+			}
+			if a.Subject == "body" && (a.Operator == assert.OpExists || a.NumericOnly()) {
+				return nil, fmt.Errorf("invalid assertion %q, expected body=, body!=, body*= or body~=", text)
+			}
+			if a.Subject == "body_size" || a.NumericOnly() {
+				if a.limit, err = a.ParseLimit(false); err != nil {
+					return nil, err
+				}
+			}
+		case "latency":
+			if !a.Numeric() {
+				return nil, fmt.Errorf("invalid assertion %q, expected latency<=<duration>", text)
+			}
+			if a.limit, err = a.ParseLimit(true); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid assertion %q, unknown subject %q, expected status, header, json, body, body_size or latency", text, a.Subject)
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions, nil
+}
+
+// needsBody reports whether checking the assertions needs the whole response body in memory.
+func needsBody(assertions []Assertion) bool {
+	for _, a := range assertions {
+		if a.Subject == "json" || a.Subject == "body" {
+			return true
+		}
+	}
+	return false
+}
+
+// assertsStatus reports whether the assertions decide which statuses are fine, instead of any 2xx.
+func assertsStatus(assertions []Assertion) bool {
+	for _, a := range assertions {
+		if a.Subject == "status" {
+			return true
+		}
+	}
+	return false
+}
+
+// response is what assertions are checked against, the body only when needsBody.
+type response struct {
+	status   int
+	header   http.Header
+	body     []byte
+	size     int64
+	latency  time.Duration
+	document any // body decoded as JSON on first use
+	decoded  bool
+	jsonErr  error
+}
+
+// check returns an empty string when the response passes, otherwise what was found instead.
+func (a Assertion) check(r *response) string {
+	switch a.Subject {
+	case "status":
+		matched := false
+		code := strconv.Itoa(r.status)
+		for _, status := range a.statuses {
+			if status == code || (strings.HasSuffix(status, "xx") && status[0] == code[0]) {
+				matched = true
+			}
+		}
+		if !a.Listed(matched) {
+			return "status " + code
+		}
+	case "header":
+		values, ok := r.header[http.CanonicalHeaderKey(a.Key)]
+		if !ok {
+			return "no such header"
+		}
+		if value := strings.Join(values, ", "); !a.CompareText(value) {
+			return fmt.Sprintf("%q", value)
+		}
+	case "json":
+		if !r.decoded {
+			r.decoded = true
+			r.jsonErr = json.Unmarshal(r.body, &r.document)
+		}
+		if r.jsonErr != nil {
+			return "body is not JSON"
+		}
+		value, ok := assert.Lookup(r.document, a.Key)
+		if !ok {
+			return "no such path"
+		}
+		if a.NumericOnly() {
+			number, isNumber := assert.Number(value)
+			if !isNumber {
+				return fmt.Sprintf("%q", assert.Format(value))
+			}
+			if !a.CompareNumber(number, a.limit) {
+				return assert.Format(value)
+			}
+		} else if !a.CompareText(assert.Format(value)) {
+			return fmt.Sprintf("%q", assert.Format(value))
+		}
+	case "body":
+		if !a.CompareText(string(r.body)) {
+			return fmt.Sprintf("%d bytes not matching", len(r.body))
+		}
+	case "body_size":
+		if !a.CompareNumber(float64(r.size), a.limit) {
+			return fmt.Sprintf("%d bytes", r.size)
+		}
+	case "latency":
+		if !a.CompareNumber(r.latency.Seconds(), a.limit) {
+			return r.latency.Round(time.Microsecond).String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseAssertions(t *testing.T) {
+	tests := []struct {
+		text    string
+		stream  bool
+		wantErr bool
+	}{
+		{"status=200,201", false, false},
+		{"status=2xx", false, false},
+		{"status!=5xx", false, false},
+		{"status<300", false, true},
+		{"status=ok", false, true},
+		{"status=2x", false, true},
+		{"header:Content-Type", false, false},
+		{"header:Content-Type*=json", true, false},
+		{"header", false, true},
+		{"json:$.status=ok", false, false},
+		{"json:$.count>=3", false, false},
+		{"json:$.count>=many", false, true},
+		{"json=ok", false, true},
+		{"json:$.status=ok", true, true},
+		{"body*=hello", false, false},
+		{"body", false, true},
+		{"body<10", false, true},
+		{"body_size<=1024", false, false},
+		{"body_size<=1k", false, true},
+		{"latency<=200ms", false, false},
+		{"latency<=200", false, true},
+		{"latency*=1s", false, true},
+		{"latency<=1s", true, false},
+		{"trailer:grpc-status", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, err := ParseAssertions([]string{tt.text}, tt.stream)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAssertions(%q, stream %v) error = %v, want error %v", tt.text, tt.stream, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	ok := func() *response {
+		return &response{
+			status:  200,
+			header:  http.Header{"Content-Type": {"application/json"}},
+			body:    []byte(`{"status":"ok","count":3,"items":[{"id":"a"}]}`),
+			size:    46,
+			latency: 150 * time.Millisecond,
+		}
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"status=200", ""},
+		{"status=2xx", ""},
+		{"status=201,4xx", "status 200"},
+		{"status!=2xx", "status 200"},
+		{"header:Content-Type", ""},
+		{"header:content-type*=json", ""},
+		{"header:Content-Type=text/plain", `"application/json"`},
+		{"header:X-Missing", "no such header"},
+		{"json:$.status=ok", ""},
+		{"json:$.items[0].id=a", ""},
+		{"json:$.count>=3", ""},
+		{"json:$.count>3", "3"},
+		{"json:$.status>3", `"ok"`},
+		{"json:$.missing", "no such path"},
+		{"body*=items", ""},
+		{"body=ok", "46 bytes not matching"},
+		{"body_size<=46", ""},
+		{"body_size<46", "46 bytes"},
+		{"latency<=200ms", ""},
+		{"latency<100ms", "150ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assertions, err := ParseAssertions([]string{tt.text}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := assertions[0].check(ok()); got != tt.want {
+				t.Errorf("check(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	notJson := ok()
+	notJson.body = []byte("<html>")
+	assertions, _ := ParseAssertions([]string{"json:$.status"}, false)
+	if got := assertions[0].check(notJson); got != "body is not JSON" {
+		t.Errorf("check on an HTML body = %q, want %q", got, "body is not JSON")
+	}
+}
@@ -1,15 +1,22 @@
 package http
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
 	"fmt"
+	"generator/load/src/retry"
+	"generator/load/src/stats"
+	"generator/load/src/telemetry"
 	"generator/load/src/util"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
-	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type HttpReq struct {
@@ -17,48 +24,31 @@ type HttpReq struct {
 	requestBody string // body to be sent with the request "if POST request".
 	reqNum int // number of requests to be done.
 	workerConc int // number of concurrent requests at the same time.
-	httpMethod string // GET, POST, PUT, DELETE 
+	httpMethod string // GET, POST, PUT, DELETE
 	timeout int // maximum number of seconds per request.
-	maxRetries int // maximum number of retries per failed request.
+	retry retry.Policy // when and how often failed requests are retried.
 	fileSize int // size of the file to be uploaded
+	rate float64 // requests per second to dispatch at, 0 dispatches all requests at once.
+	assertions []Assertion // checks every response must pass, from --assert.
+	keepBody bool // whether the assertions need whole response bodies.
+	statusAsserted bool // whether the assertions decide which statuses are fine instead of any 2xx.
+	hold time.Duration // how long SSE subscribers are held open, reconnecting, 0 opens each stream once.
+	reconnectDelay time.Duration // wait before reconnecting a held subscriber until the server sets one with retry.
+	version string // HTTP versions the client may use, one of the Version* constants.
+	tlsConfig *tls.Config // certificates, verification and versions of TLS connections, nil for the defaults.
 }
 
-type requestStat struct {
-	latency float64
-	method string
-	successful bool
-}
-
-type sseRequestStat struct {
-	latency float32
-	events int
-	successful bool
-}
-
-type csRequestStat struct {
-	latency float64
-	successful bool
-}
-
-type requestStats struct {
-	request_stats []requestStat
-	throughput float64
-	total_time float64
-	successful_requests int
-	failed_requests int
-}	
-
 
 ////////////////////////// Exported Methods /////////////////////////
 
-func GenerateHttpReq(destination string, requestbody_path string, reqNum int, workerConc int, httpMethod string, timeout int, maxRetries int, fileSize int) *HttpReq {
+func GenerateHttpReq(destination string, requestbody_path string, reqNum int, workerConc int, httpMethod string, timeout int, policy retry.Policy, fileSize int, rate float64, assertions []Assertion) *HttpReq {
 
 	var err error
 	var requestBodyBytes []byte
-	if httpMethod == "POST" || httpMethod == "PUT" {
+	if takesBody(httpMethod) && requestbody_path != "" {
 		requestBodyBytes, err = os.ReadFile(requestbody_path)
 		if err != nil {
-			println("Error reading request body file:", err.Error())
+			slog.Warn("cannot read the request body file, sending an empty body", "path", requestbody_path, "error", err)
 			requestBodyBytes = []byte{}
 		}
 	}
@@ -70,304 +60,519 @@ func GenerateHttpReq(destination string, requestbody_path string, reqNum int, wo
 		workerConc: workerConc,
 		httpMethod: httpMethod,
 		timeout: timeout,
-		maxRetries: maxRetries,
+		retry: policy,
 		fileSize: fileSize,
+		rate: rate,
+		assertions: assertions,
+		keepBody: needsBody(assertions),
+		statusAsserted: assertsStatus(assertions),
 	}
 }
 
-func (h *HttpReq) GenerateSseLoad(){
-	time_before := time.Now()
-	client := h.generateClient(true) // timeout for SSE
-	var wg sync.WaitGroup
-	var result_collector sync.WaitGroup
-	output := make(chan sseRequestStat)
-	for i:= 0; i < h.reqNum; i++ {
-		wg.Add(1)
-		go h.generate_one_sse_load(client, &wg, output)
-	}
-	go func (ch <- chan sseRequestStat, wg *sync.WaitGroup)  {
-		wg.Add(1)
-		defer wg.Done()
-		var total_latency float32 = 0
-		var total_count int = 0
-		var successful int = 0
-		var total_events int = 0
-		for {
-			select {
-			case val, ok := <-ch:
-				if !ok {
-					fmt.Printf("Average Latency: %.3f\n", total_latency/float32(total_count))
-					fmt.Printf("Total Success percent: %.2f%%\n", float32(successful)/float32(total_count)*100)
-					fmt.Printf("Total number of requests: %d\n", total_count)
-					fmt.Printf("Average Events: %d\n", total_events/total_count)
-					return
-				}
-				println("Latency: ", val.latency)
-				println("Events Received: ", val.events)
-				println("Successful: ", val.successful)
-				total_latency += val.latency
-				total_count ++
-				total_events += val.events
-				if val.successful {
-					successful ++
-				}
-			}
-		}
-	}(output, &result_collector)
-
-
-	wg.Wait()
-	close(output)
-	result_collector.Wait()
-
-	time_after := time.Now()
-	total_time_taken := time_after.Sub(time_before).Seconds()
-
-	fmt.Printf("Total time taken: %.4f Second\n", float32(total_time_taken))
-	fmt.Printf("Total throughput: %.4f Request/Second\n", float32(h.reqNum)/float32(total_time_taken))
+// SetHold makes GenerateSseLoad hold every subscriber open for hold, reconnecting after delay (or
+// the retry time sent by the server) whenever its stream ends or fails.
+func (h *HttpReq) SetHold(hold time.Duration, delay time.Duration) {
+	h.hold = hold
+	h.reconnectDelay = delay
 }
 
+func (h *HttpReq) GenerateSseLoad(collector *stats.Collector){
+	schedule := util.NewSchedule(h.rate)
+	if h.hold > 0 {
+		client := h.generateClient(false) // the hold duration ends the streams instead
+		schedule.Dispatch(h.reqNum, h.workerConc, func(worker int, i int, intended time.Time) {
+			h.generate_one_sse_subscriber(client, collector, worker, i, intended)
+		})
+		return
+	}
+	client := h.generateClient(true) // timeout for SSE
+	schedule.Dispatch(h.reqNum, h.workerConc, func(worker int, i int, intended time.Time) {
+		h.generate_one_sse_load(client, collector, worker, i, intended)
+	})
+}
 
-func (h *HttpReq) GenerateGenericLoad() {
-	time_before := time.Now()
-	client := h.generateClient(false) // no timeout for Generic Unary
-	var wg sync.WaitGroup
-	var result_collector sync.WaitGroup
-	output := make(chan requestStat)
-	for i:= 0; i < h.reqNum; i++ {
-		wg.Add(1)
-		go h.generate_one_generic_load(client, &wg, output)
-	}
-	go func (ch <- chan requestStat, wg *sync.WaitGroup)  {
-		wg.Add(1)
-		defer wg.Done()
-		var total_latency float32 = 0
-		var total_count int = 0
-		var successful int = 0
-		for {
-			select {
-			case val, ok := <-ch:
-				if !ok {
-					fmt.Printf("Average Latency: %.3f\n", total_latency/float32(total_count))
-					fmt.Printf("Total Success percent: %.2f%%\n", float32(successful)/float32(total_count)*100)
-					fmt.Printf("Total number of requests: %d\n", total_count)
-					return
-				}
-				println("Latency: ", val.latency)
-				println("Successful: ", val.successful)
-				total_latency += float32(val.latency)
-				total_count ++
-				if val.successful {
-					successful ++
-				}
-			}
-		}
-	}(output, &result_collector)
-
-
-	wg.Wait()
-	close(output)
-	result_collector.Wait()
-
-	time_after := time.Now()
-	total_time_taken := time_after.Sub(time_before).Seconds()
 
-	fmt.Printf("Total time taken: %.4f Second\n", float32(total_time_taken))
-	fmt.Printf("Total throughput: %.4f Request/Second\n", float32(h.reqNum)/float32(total_time_taken))
+func (h *HttpReq) GenerateGenericLoad(collector *stats.Collector) {
+	client := h.generateClient(false) // attempts are limited by the try timeout of the retry policy
+	schedule := util.NewSchedule(h.rate)
+	schedule.Dispatch(h.reqNum, h.workerConc, func(worker int, i int, intended time.Time) {
+		h.generate_one_generic_load(client, collector, worker, i, intended)
+	})
 }
 
-func (h *HttpReq) GenerateCsLoad() {
+// GenerateCsLoad uploads a generated file with every request, it fails without sending any when
+// the file can't be generated.
+func (h *HttpReq) GenerateCsLoad(collector *stats.Collector) error {
 	client := h.generateClient(false)
 
-	time_before := time.Now()
-
 	// Generate file
 	filepath, err := util.GenerateFile("demo.txt", h.fileSize)
 	if err != nil {
-		return 
-	}
-	var wg sync.WaitGroup
-	var result_collector sync.WaitGroup
-	output := make(chan csRequestStat)
-	for i:= 0; i < h.reqNum; i++ {
-		wg.Add(1)
-		go h.generate_one_cs_load(client, &wg, output, filepath)
-	}
-	go func (ch <- chan csRequestStat, wg *sync.WaitGroup)  {
-		wg.Add(1)
-		defer wg.Done()
-		var total_latency float32 = 0
-		var total_count int = 0
-		var successful int = 0
-		for {
-			select {
-			case val, ok := <-ch:
-				if !ok {
-					fmt.Printf("Average Latency: %.3f\n", total_latency/float32(total_count))
-					fmt.Printf("Total Success percent: %.2f%%\n", float32(successful)/float32(total_count)*100)
-					fmt.Printf("Total number of requests: %d\n", total_count)
-					return
-				}
-				println("Latency: ", val.latency)
-				println("Successful: ", val.successful)
-				total_latency += float32(val.latency)
-				total_count ++
-				if val.successful {
-					successful ++
-				}
-			}
-		}
-	}(output, &result_collector)
-
-
-	wg.Wait()
-	close(output)
-	result_collector.Wait()
-
-	time_after := time.Now()
-	total_time_taken := time_after.Sub(time_before).Seconds()
-
-	fmt.Printf("Total time taken: %.4f Second\n", float32(total_time_taken))
-	fmt.Printf("Total throughput: %.4f Request/Second\n", float32(h.reqNum)/float32(total_time_taken))
-
+		return fmt.Errorf("cannot generate the file to upload: %w", err)
+	}
 	// Delete the generated file
-	os.Remove(filepath)
-	return
+	defer os.Remove(filepath)
+	schedule := util.NewSchedule(h.rate)
+	schedule.Dispatch(h.reqNum, h.workerConc, func(worker int, i int, intended time.Time) {
+		h.generate_one_cs_load(client, collector, worker, i, filepath, intended)
+	})
+	return nil
 }
 
 ///////////////////////// Internal Methods /////////////////////////
 
+// takesBody reports whether requests of method carry the request body.
+func takesBody(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
 func (h *HttpReq) generateClient(need_timeout bool) *http.Client {
-	var timeout int = 0 
+	var timeout int = 0
 	if need_timeout { // for unary, else for SSE no timeout
 		timeout = h.timeout
 	}
 	client := &http.Client{
 		Timeout: time.Duration(timeout) * time.Second  ,
+		Transport: h.transport(),
 	}
 	return client
 }
 
 
-func (h *HttpReq) generate_one_generic_load(client * http.Client, wg *sync.WaitGroup, ch chan requestStat) {
-	defer wg.Done()
-	var successful_request bool = false
-	var err error = nil
-	var resp *http.Response
-	
+// startSpan starts the client span of one request, traced only when OTLP trace export is on.
+func (h *HttpReq) startSpan(method string) (context.Context, trace.Span) {
+	return telemetry.StartSpan(context.Background(), "lgen "+method,
+		attribute.String("http.request.method", method),
+		attribute.String("url.full", h.destination))
+}
 
-	time_before := time.Now()
 
-	for attempt := 0; attempt < h.maxRetries; attempt++ {
-		resp, err = client.Post(h.destination, "application/json", strings.NewReader(h.requestBody))
-		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			successful_request = true
-			resp.Body.Close()
-			break
-		}else if resp != nil {
-			resp.Body.Close()
-		}
+func (h *HttpReq) generate_one_generic_load(client * http.Client, collector *stats.Collector, worker int, request int, intended time.Time) {
+	result := stats.Result{
+		Worker: worker,
+		Request: request,
+		Intended: intended,
+		Start: time.Now(),
 	}
-	time_after := time.Now()
-	request_latency := time_after.Sub(time_before).Seconds()
-
+	collector.Dispatch()
+	ctx, span := h.startSpan(h.httpMethod)
+	defer func() { telemetry.EndSpan(span, result, "http.response.status_code") }()
 
-	ch <- requestStat{
-		latency: request_latency,
-		method: h.httpMethod,
-		successful: successful_request}
+	h.retry.Run(ctx, &result, func(ctx context.Context) {
+		h.generic_try(ctx, client, &result)
+	})
+	result.End = time.Now()
 
+	collector.Record(result)
 	return
 }
 
-
-func (h *HttpReq) generate_one_sse_load(client * http.Client, wg *sync.WaitGroup, ch chan sseRequestStat) {
-	defer wg.Done()
-
-	time_before := time.Now()
-
-	req, err := http.NewRequest("GET", h.destination, nil)
-	// req.Header.Set("Accept", "text/event-stream") I think no need for it, right now at least
+// generic_try sends the request once, the outcome of the attempt is left in result.
+func (h *HttpReq) generic_try(ctx context.Context, client *http.Client, result *stats.Result) {
+	trace, ctx := newPhaseTrace(ctx)
+	defer func() { result.Phases = trace.done() }()
+	var body io.Reader = nil
+	if takesBody(h.httpMethod) {
+		body = strings.NewReader(h.requestBody)
+		result.BytesOut += int64(len(h.requestBody))
+	}
+	req, err := http.NewRequestWithContext(ctx, h.httpMethod, h.destination, body)
 	if err != nil {
-		ch <- sseRequestStat{
-			latency: 0,
-			events: 0,
-			successful: false}
+		result.ErrorClass = stats.ErrorRequest
+		result.Error = err.Error()
 		return
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	telemetry.InjectHttp(ctx, req.Header)
+	sent := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		ch <- sseRequestStat{
-			latency: 0,
-			events: 0,
-			successful: false}
+		result.ErrorClass = stats.ClassifyError(err)
+		result.Error = err.Error()
 		return
 	}
 	defer resp.Body.Close()
-	
-	attempts := 0
-	reader := bufio.NewReader(resp.Body)
-	events := 0
+	result.Status = resp.StatusCode
+	result.Proto = resp.Proto
+	result.ErrorClass = h.statusClass(resp.StatusCode)
+	result.Successful = result.ErrorClass == ""
+	if err := h.readResponse(resp, result, sent); err != nil {
+		result.Successful = false
+		result.ErrorClass = bodyReadClass(err)
+		result.Error = err.Error()
+		return
+	}
+	if !result.Successful && result.Assertion == "" {
+		result.Error = resp.Status
+	}
+}
+
+
+func (h *HttpReq) generate_one_sse_load(client * http.Client, collector *stats.Collector, worker int, request int, intended time.Time) {
+
+	result := stats.Result{
+		Worker: worker,
+		Request: request,
+		Intended: intended,
+		Start: time.Now(),
+	}
+	collector.Dispatch()
+	ctx, span := h.startSpan(http.MethodGet)
+	defer func() { telemetry.EndSpan(span, result, "http.response.status_code") }()
+
+	// Only connecting is retried, the client timeout caps the whole stream instead of an attempt.
+	policy := h.retry
+	policy.TryTimeout = 0
+	var resp *http.Response
+	var trace *phaseTrace
+	var timing *stats.StreamTiming
+	policy.Run(ctx, &result, func(ctx context.Context) {
+		trace, ctx = newPhaseTrace(ctx)
+		timing = stats.NewStreamTiming()
+		resp = h.sse_connect(ctx, client, &result, "")
+		if resp == nil {
+			result.Phases = trace.done()
+		}
+	})
+	// The first attempt only connected, the latency of a stream spans all of its events.
+	result.FirstTryEnd = time.Time{}
+	if resp == nil {
+		result.End = time.Now()
+		collector.Record(result)
+		return
+	}
+	defer resp.Body.Close()
+	timing.Headers()
+	result.Stream = timing
+
+	parser := newSseParser(resp.Body)
 	for {
-		line, err := reader.ReadString('\n')
+		event, err := parser.Next()
 		if err != nil {
+			result.BytesIn += parser.read
 			if err == io.EOF {
 				break
 			}
-			if attempts >= h.maxRetries{
+			// The client timeout caps how long a stream is followed, reaching it ends the stream.
+			class := bodyReadClass(err)
+			if class == stats.ErrorTimeout {
+				break
+			}
+			result.End = time.Now()
+			result.ErrorClass = class
+			result.Error = err.Error()
+			result.Phases = trace.done()
+			timing.Finish()
+			collector.Record(result)
+			return
+		}
+		timing.Event()
+		result.Events++
+		if result.EventTypes == nil {
+			result.EventTypes = make(map[string]int)
+		}
+		result.EventTypes[event.Type]++
+		result.LastEventId = event.Id
+	}
+
+	result.End = time.Now()
+	result.Successful = true
+	result.Phases = trace.done() // the transfer phase of a stream lasts until it ends
+	timing.Finish()
+	collector.Record(result)
+}
+
+// generate_one_sse_subscriber follows a stream for the whole hold duration, reconnecting with the
+// Last-Event-ID of the last event whenever the stream ends or fails, as browsers do. Failed
+// connections are retried with the backoff of the retry policy, at least sseMinFailedReconnect
+// apart. The subscriber fails when any connection attempt fails, the first connection is the one traced.
+func (h *HttpReq) generate_one_sse_subscriber(client * http.Client, collector *stats.Collector, worker int, request int, intended time.Time) {
+
+	result := stats.Result{
+		Worker: worker,
+		Request: request,
+		Intended: intended,
+		Start: time.Now(),
+		Successful: true,
+	}
+	collector.Dispatch()
+	span_ctx, span := h.startSpan(http.MethodGet)
+	defer func() { telemetry.EndSpan(span, result, "http.response.status_code") }()
+	ctx, cancel := context.WithTimeout(span_ctx, h.hold)
+	defer cancel()
+
+	timing := stats.NewStreamTiming()
+	delay := h.reconnectDelay
+	lastId := ""
+	failures := 0 // consecutive failed connections, backed off like retries
+	for connection := 0; ; connection++ {
+		if connection > 0 {
+			wait := delay
+			if failures > 0 {
+				wait = max(wait, h.retry.Delay(failures), sseMinFailedReconnect)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			result.Reconnects++
+		}
+
+		connect_ctx := ctx
+		var trace *phaseTrace
+		if connection == 0 {
+			trace, connect_ctx = newPhaseTrace(ctx)
+		}
+		attempt := stats.Result{Start: time.Now()}
+		resp := h.sse_connect(connect_ctx, client, &attempt, lastId)
+		result.BytesIn += attempt.BytesIn
+		if trace != nil && resp == nil {
+			result.Phases = trace.done()
+		}
+		if resp == nil {
+			if ctx.Err() != nil {
+				break // the hold ended while connecting
+			}
+			result.Successful = false
+			result.Status = attempt.Status
+			result.ErrorClass = attempt.ErrorClass
+			result.Error = attempt.Error
+			result.Body = attempt.Body
+			result.Assertion = attempt.Assertion
+			failures++
+			continue
+		}
+		failures = 0
+		if result.Status == 0 || result.Successful {
+			result.Status = attempt.Status
+		}
+		result.Proto = attempt.Proto
+		if result.Stream == nil {
+			timing.Headers()
+			result.Stream = timing
+		}
+
+		// Dropped streams are reconnected rather than failed, that's what the reconnects count.
+		parser := newSseParser(resp.Body)
+		parser.lastId = lastId
+		for {
+			event, err := parser.Next()
+			if err != nil {
+				result.BytesIn += parser.read
 				break
-			} else {
-				attempts ++
-				continue
 			}
+			timing.Event()
+			result.Events++
+			if result.EventTypes == nil {
+				result.EventTypes = make(map[string]int)
+			}
+			result.EventTypes[event.Type]++
+			result.MissedEvents += missedEvents(lastId, event.Id)
+			lastId = event.Id
+			result.LastEventId = event.Id
+		}
+		resp.Body.Close()
+		if trace != nil {
+			result.Phases = trace.done()
 		}
-		if len(line) > 6 && line[:5] == "data:" {
-			events ++
-		} else if len(line) == 0 {
+		if parser.retry > 0 {
+			delay = parser.retry
+		}
+		if ctx.Err() != nil {
 			break
 		}
 	}
 
-	time_after := time.Now()
-	request_latency := time_after.Sub(time_before).Seconds()
+	result.End = time.Now()
+	timing.Finish()
+	collector.Record(result)
+}
 
-	ch <- sseRequestStat{
-		latency: float32(request_latency),
-		events: events,
-		successful: true}
+// sse_connect opens the stream once, returning the response to read the events from, or nil
+// with the failure left in result. A non empty lastEventId is sent as Last-Event-ID, so the
+// server resumes the stream after the last event received.
+func (h *HttpReq) sse_connect(ctx context.Context, client *http.Client, result *stats.Result, lastEventId string) *http.Response {
+	req, err := http.NewRequestWithContext(ctx, "GET", h.destination, nil)
+	// req.Header.Set("Accept", "text/event-stream") I think no need for it, right now at least
+	if err != nil {
+		result.ErrorClass = stats.ErrorRequest
+		result.Error = err.Error()
+		return nil
+	}
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	telemetry.InjectHttp(ctx, req.Header)
+	resp, err := client.Do(req)
+	if err != nil {
+		result.ErrorClass = stats.ClassifyError(err)
+		result.Error = err.Error()
+		return nil
+	}
+	result.Status = resp.StatusCode
+	result.Proto = resp.Proto
+	result.ErrorClass = h.statusClass(resp.StatusCode)
+	if result.ErrorClass != "" {
+		readBody(resp.Body, result)
+		resp.Body.Close()
+		result.Error = resp.Status
+		return nil
+	}
+	// Streams are asserted on their response headers, latency is the time until they arrived.
+	if h.assert(&response{status: resp.StatusCode, header: resp.Header, latency: time.Since(result.Start)}, result) {
+		resp.Body.Close()
+		return nil
+	}
+	return resp
 }
 
 
-func (h *HttpReq) generate_one_cs_load(client * http.Client, wg *sync.WaitGroup, ch chan csRequestStat, path string){
-	defer wg.Done()
+func (h *HttpReq) generate_one_cs_load(client * http.Client, collector *stats.Collector, worker int, request int, path string, intended time.Time){
+
+	result := stats.Result{
+		Worker: worker,
+		Request: request,
+		Intended: intended,
+		Start: time.Now(),
+	}
+	collector.Dispatch()
+	ctx, span := h.startSpan(http.MethodPost)
+	defer func() { telemetry.EndSpan(span, result, "http.response.status_code") }()
 
+	h.retry.Run(ctx, &result, func(ctx context.Context) {
+		h.cs_try(ctx, client, path, &result)
+	})
+	result.End = time.Now()
+
+	collector.Record(result)
+	return
+}
+
+// cs_try uploads the file once, the outcome of the attempt is left in result.
+func (h *HttpReq) cs_try(ctx context.Context, client *http.Client, path string, result *stats.Result) {
+	trace, ctx := newPhaseTrace(ctx)
+	defer func() { result.Phases = trace.done() }()
 	file, err := os.Open(path)
 	if err != nil {
-		println("Unable to open file: ", path)
-		ch <- csRequestStat{}
+		result.ErrorClass = stats.ErrorRequest
+		result.Error = err.Error()
 		return
 	}
+	defer file.Close()
 
-	req, err := http.NewRequest(http.MethodPost, h.destination, file)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.destination, file)
+	if err != nil {
+		result.ErrorClass = stats.ErrorRequest
+		result.Error = err.Error()
+		return
+	}
 	req.Header.Set("Content-Type", "application/octet-stream")
+	telemetry.InjectHttp(ctx, req.Header)
 
-	before_time := time.Now()
-
+	sent := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		println("Unable to send Client streaming request")
-		ch <- csRequestStat{}
+		result.ErrorClass = stats.ClassifyError(err)
+		result.Error = err.Error()
 		return
 	}
+	defer resp.Body.Close()
+	result.BytesOut += int64(h.fileSize)
+	result.Status = resp.StatusCode
+	result.Proto = resp.Proto
+	result.ErrorClass = h.statusClass(resp.StatusCode)
+	result.Successful = result.ErrorClass == ""
+	if !result.Successful {
+		result.Error = resp.Status
+	}
+	if err := h.readResponse(resp, result, sent); err != nil && result.Successful {
+		result.Successful = false
+		result.ErrorClass = bodyReadClass(err)
+		result.Error = err.Error()
+	}
+}
 
-	after_time := time.Now()
 
-	latency := after_time.Sub(before_time).Seconds()
+// readBody drains a response body into result.BytesIn, keeping its start in result.Body when the request failed.
+func readBody(body io.Reader, result *stats.Result) error {
+	if !result.Successful {
+		sample := make([]byte, stats.BodySampleSize)
+		n, err := io.ReadFull(body, sample)
+		result.BytesIn += int64(n)
+		result.Body = string(sample[:n])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	read, err := io.Copy(io.Discard, body)
+	result.BytesIn += read
+	return err
+}
 
-	defer resp.Body.Close()
-	ch <- csRequestStat{
-		latency: latency,
-		successful: true,
+// statusClass classes a response status, when the assertions check the status any status is left to them.
+func (h *HttpReq) statusClass(status int) string {
+	if h.statusAsserted {
+		return ""
 	}
-	return 
-}
\ No newline at end of file
+	return stats.ClassifyHttpStatus(status)
+}
+
+// readResponse reads the body of a response like readBody, then checks the assertions against
+// the response when it succeeded so far. sent is when the request went out, for latency assertions.
+func (h *HttpReq) readResponse(resp *http.Response, result *stats.Result, sent time.Time) error {
+	if !h.keepBody {
+		err := readBody(resp.Body, result)
+		if err == nil {
+			h.assert(&response{status: resp.StatusCode, header: resp.Header, size: result.BytesIn, latency: time.Since(sent)}, result)
+		}
+		return err
+	}
+	body, err := io.ReadAll(resp.Body)
+	result.BytesIn += int64(len(body))
+	if !result.Successful {
+		result.Body = string(body[:min(len(body), stats.BodySampleSize)])
+	}
+	if err != nil {
+		return err
+	}
+	h.assert(&response{status: resp.StatusCode, header: resp.Header, body: body, size: int64(len(body)), latency: time.Since(sent)}, result)
+	return nil
+}
+
+// assert checks the assertions in order against a response that succeeded so far, the first failing
+// one fails the request as an assertion error. It reports whether an assertion failed.
+func (h *HttpReq) assert(r *response, result *stats.Result) bool {
+	result.Assertion = ""
+	if result.ErrorClass != "" {
+		return false
+	}
+	for _, a := range h.assertions {
+		if got := a.check(r); got != "" {
+			result.Successful = false
+			result.ErrorClass = stats.ErrorAssertion
+			result.Assertion = a.Text
+			result.Error = fmt.Sprintf("assertion %s failed, got %s", a.Text, got)
+			if result.Body == "" && r.body != nil {
+				result.Body = string(r.body[:min(len(r.body), stats.BodySampleSize)])
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// bodyReadClass classes an error reading a response body, a timeout stays a timeout.
+func bodyReadClass(err error) string {
+	if class := stats.ClassifyError(err); class == stats.ErrorTimeout {
+		return class
+	}
+	return stats.ErrorBodyRead
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"generator/load/src/retry"
+	"generator/load/src/stats"
+)
+
+// runLoad runs the load of h against a fresh collector and returns the run summary.
+func runLoad(t *testing.T, h *HttpReq, load func(h *HttpReq, collector *stats.Collector)) *stats.Summary {
+	t.Helper()
+	collector := stats.NewCollector(stats.RunConfig{Protocol: stats.ProtocolHttp, Requests: h.reqNum})
+	collector.Start()
+	load(h, collector)
+	summary, err := collector.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestGenericLoadMethod(t *testing.T) {
+	body := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(body, []byte(`{"hello":"world"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method   string
+		wantBody string
+	}{
+		{http.MethodGet, ""},
+		{http.MethodDelete, ""},
+		{http.MethodPost, `{"hello":"world"}`},
+		{http.MethodPut, `{"hello":"world"}`},
+		{http.MethodPatch, `{"hello":"world"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			var mu sync.Mutex
+			var methods, bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				methods = append(methods, r.Method)
+				bodies = append(bodies, string(b))
+				mu.Unlock()
+			}))
+			defer server.Close()
+
+			h := GenerateHttpReq(server.URL, body, 3, 0, tt.method, 5, retry.Policy{}, 0, 0, nil)
+			summary := runLoad(t, h, (*HttpReq).GenerateGenericLoad)
+			if summary.Successful != 3 {
+				t.Errorf("%d of 3 requests succeeded", summary.Successful)
+			}
+			if want := int64(3 * len(tt.wantBody)); summary.BytesOut != want {
+				t.Errorf("sent %d bytes, want %d", summary.BytesOut, want)
+			}
+			for i := range methods {
+				if methods[i] != tt.method || bodies[i] != tt.wantBody {
+					t.Errorf("server got %s %q, want %s %q", methods[i], bodies[i], tt.method, tt.wantBody)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseMaxLine caps the length of a single line of an event stream.
+const sseMaxLine = 1024 * 1024
+
+// sseMinFailedReconnect is the least a held subscriber waits after a failed connection, so a
+// --reconnect-delay of 0 doesn't hammer a server that is down.
+const sseMinFailedReconnect = 100 * time.Millisecond
+
+// Event is one event dispatched from a text/event-stream.
+type Event struct {
+	Type string // the event field, "message" when the event had none
+	Id   string // last event id of the stream, carried over from earlier events
+	Data string // the data lines of the event joined with newlines
+}
+
+// sseParser reads a text/event-stream as specified by the HTML standard: lines end with CRLF, LF
+// or CR, a blank line dispatches the event buffered so far, lines starting with a colon are comments
+// and the event, data, id and retry fields are interpreted, other fields are ignored.
+type sseParser struct {
+	scanner *bufio.Scanner
+	read    int64         // bytes consumed from the stream
+	first   bool          // whether the next line is the first of the stream, which may start with a BOM
+	lastId  string        // last event id, set by id fields
+	retry   time.Duration // reconnection time set by the last retry field, 0 when none was sent
+
+	eventType string
+	data      strings.Builder
+}
+
+func newSseParser(r io.Reader) *sseParser {
+	p := &sseParser{scanner: bufio.NewScanner(r), first: true}
+	p.scanner.Buffer(make([]byte, 4096), sseMaxLine)
+	p.scanner.Split(p.splitLines)
+	return p
+}
+
+// splitLines splits the stream on CRLF, LF or CR, a CR at the end of the buffered data waits for
+// more to tell a CRLF from a lone CR.
+func (p *sseParser) splitLines(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.IndexAny(data, "\r\n")
+	if i < 0 {
+		if atEOF && len(data) > 0 {
+			// An unterminated last line can't complete an event, it is consumed and dropped.
+			p.read += int64(len(data))
+			return len(data), nil, nil
+		}
+		return 0, nil, nil
+	}
+	advance := i + 1
+	if data[i] == '\r' {
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			advance++
+		}
+	}
+	p.read += int64(advance)
+	return advance, data[:i], nil
+}
+
+// Next returns the next dispatched event, io.EOF once the stream ended, an event left incomplete at
+// the end of the stream is dropped.
+func (p *sseParser) Next() (Event, error) {
+	for p.scanner.Scan() {
+		line := p.scanner.Text()
+		if p.first {
+			line = strings.TrimPrefix(line, "\uFEFF")
+			p.first = false
+		}
+		if line == "" {
+			if event, ok := p.dispatch(); ok {
+				return event, nil
+			}
+			continue
+		}
+		if line[0] == ':' {
+			continue // comment, often sent as a keep-alive
+		}
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+		switch field {
+		case "event":
+			p.eventType = value
+		case "data":
+			p.data.WriteString(value)
+			p.data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				p.lastId = value
+			}
+		case "retry":
+			if milliseconds, err := strconv.ParseUint(value, 10, 63); err == nil {
+				p.retry = time.Duration(milliseconds) * time.Millisecond
+			}
+		}
+	}
+	if err := p.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// dispatch completes the buffered event, an event without data isn't dispatched.
+func (p *sseParser) dispatch() (Event, bool) {
+	eventType := p.eventType
+	p.eventType = ""
+	if p.data.Len() == 0 {
+		return Event{}, false
+	}
+	data := strings.TrimSuffix(p.data.String(), "\n")
+	p.data.Reset()
+	if eventType == "" {
+		eventType = "message"
+	}
+	return Event{Type: eventType, Id: p.lastId, Data: data}, true
+}
+
+// missedEvents counts the events skipped from the event id previous to current, for streams that
+// number their events one by one. Ids that aren't numbers, or don't move forward, miss nothing.
+func missedEvents(previous string, current string) int {
+	from, err := strconv.ParseInt(previous, 10, 64)
+	if err != nil {
+		return 0
+	}
+	to, err := strconv.ParseInt(current, 10, 64)
+	if err != nil || to <= from+1 {
+		return 0
+	}
+	return int(to - from - 1)
+}
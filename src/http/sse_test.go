@@ -0,0 +1,218 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"generator/load/src/retry"
+	"generator/load/src/stats"
+)
+
+// parseStream reads every event of stream, one byte at a time when slow is set so CRLF line
+// endings are split across reads.
+func parseStream(t *testing.T, stream string, slow bool) ([]Event, *sseParser) {
+	t.Helper()
+	var r io.Reader = strings.NewReader(stream)
+	if slow {
+		r = iotest.OneByteReader(r)
+	}
+	p := newSseParser(r)
+	var events []Event
+	for {
+		event, err := p.Next()
+		if err == io.EOF {
+			return events, p
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestSseParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		events []Event
+		lastId string
+		retry  time.Duration
+	}{
+		{"LF", "data: a\n\ndata: b\n\n", []Event{{"message", "", "a"}, {"message", "", "b"}}, "", 0},
+		{"CRLF", "data: a\r\n\r\ndata: b\r\n\r\n", []Event{{"message", "", "a"}, {"message", "", "b"}}, "", 0},
+		{"CR", "data: a\r\rdata: b\r\r", []Event{{"message", "", "a"}, {"message", "", "b"}}, "", 0},
+		{"mixed line endings", "data: a\r\n\ndata: b\r\rdata: c\n\r\n", []Event{{"message", "", "a"}, {"message", "", "b"}, {"message", "", "c"}}, "", 0},
+		{"BOM", "\uFEFFdata: a\n\n", []Event{{"message", "", "a"}}, "", 0},
+		{"BOM only at the start", "data: a\n\n\uFEFFdata: b\n\n", []Event{{"message", "", "a"}}, "", 0},
+		{"multi-line data", "data: one\ndata:two\ndata:  three\n\n", []Event{{"message", "", "one\ntwo\n three"}}, "", 0},
+		{"empty data line", "data\ndata\n\n", []Event{{"message", "", "\n"}}, "", 0},
+		{"event type", "event: update\ndata: x\n\ndata: y\n\n", []Event{{"update", "", "x"}, {"message", "", "y"}}, "", 0},
+		{"type without data is dropped", "event: ping\n\ndata: y\n\n", []Event{{"message", "", "y"}}, "", 0},
+		{"ids carry over", "id: 1\ndata: a\n\ndata: b\n\nid: 3\ndata: c\n\n", []Event{{"message", "1", "a"}, {"message", "1", "b"}, {"message", "3", "c"}}, "3", 0},
+		{"id without data", "data: a\n\nid: 7\n\n", []Event{{"message", "", "a"}}, "7", 0},
+		{"id with NUL ignored", "id: 1\ndata: a\n\nid: 2\x00\ndata: b\n\n", []Event{{"message", "1", "a"}, {"message", "1", "b"}}, "1", 0},
+		{"empty id resets", "id: 1\ndata: a\n\nid\ndata: b\n\n", []Event{{"message", "1", "a"}, {"message", "", "b"}}, "", 0},
+		{"retry", "retry: 2500\ndata: a\n\n", []Event{{"message", "", "a"}}, "", 2500 * time.Millisecond},
+		{"invalid retry ignored", "retry: 1000\nretry: soon\nretry: -5\ndata: a\n\n", []Event{{"message", "", "a"}}, "", time.Second},
+		{"comments", ": keep-alive\n:\ndata: a\n: more\n\n", []Event{{"message", "", "a"}}, "", 0},
+		{"unknown fields", "foo: bar\ndata: a\n\n", []Event{{"message", "", "a"}}, "", 0},
+		{"field without colon", "data\n\n", []Event{{"message", "", ""}}, "", 0},
+		{"incomplete last event dropped", "data: a\n\ndata: b\n", []Event{{"message", "", "a"}}, "", 0},
+		{"unterminated last line dropped", "data: a\n\ndata: b", []Event{{"message", "", "a"}}, "", 0},
+		{"empty stream", "", nil, "", 0},
+	}
+	for _, tt := range tests {
+		for _, slow := range []bool{false, true} {
+			name := tt.name
+			if slow {
+				name += " byte by byte"
+			}
+			t.Run(name, func(t *testing.T) {
+				events, p := parseStream(t, tt.stream, slow)
+				if !reflect.DeepEqual(events, tt.events) {
+					t.Errorf("events = %q, want %q", events, tt.events)
+				}
+				if p.lastId != tt.lastId {
+					t.Errorf("lastId = %q, want %q", p.lastId, tt.lastId)
+				}
+				if p.retry != tt.retry {
+					t.Errorf("retry = %v, want %v", p.retry, tt.retry)
+				}
+				if p.read != int64(len(tt.stream)) {
+					t.Errorf("read %d bytes, want %d", p.read, len(tt.stream))
+				}
+			})
+		}
+	}
+}
+
+func TestSseParserLineTooLong(t *testing.T) {
+	p := newSseParser(strings.NewReader("data: " + strings.Repeat("x", sseMaxLine) + "\n\n"))
+	if _, err := p.Next(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Next() on an oversized line error = %v, want a scanner error", err)
+	}
+}
+
+func TestMissedEvents(t *testing.T) {
+	tests := []struct {
+		previous, current string
+		want              int
+	}{
+		{"1", "2", 0},
+		{"1", "5", 3},
+		{"0", "101", 100},
+		{"5", "5", 0},
+		{"5", "3", 0},
+		{"", "3", 0},
+		{"a", "c", 0},
+		{"1", "x", 0},
+		{"-2", "1", 2},
+	}
+	for _, tt := range tests {
+		if got := missedEvents(tt.previous, tt.current); got != tt.want {
+			t.Errorf("missedEvents(%q, %q) = %d, want %d", tt.previous, tt.current, got, tt.want)
+		}
+	}
+}
+
+// resultSink keeps every result recorded during a run.
+type resultSink struct {
+	mu      sync.Mutex
+	results []stats.Result
+}
+
+func (s *resultSink) Record(r stats.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, r)
+}
+
+func (s *resultSink) Close() error { return nil }
+
+// holdSubscriber holds a single subscriber to url open for hold and returns its result.
+func holdSubscriber(t *testing.T, url string, hold time.Duration, delay time.Duration) stats.Result {
+	t.Helper()
+	h := GenerateHttpReq(url, "", 1, 1, "SSE", 5, retry.Policy{}, 0, 0, nil)
+	h.SetHold(hold, delay)
+	sink := &resultSink{}
+	collector := stats.NewCollector(stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: stats.ModeSse, Requests: 1})
+	collector.AddSink(sink)
+	collector.Start()
+	h.GenerateSseLoad(collector)
+	if _, err := collector.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.results) != 1 {
+		t.Fatalf("recorded %d results, want 1", len(sink.results))
+	}
+	return sink.results[0]
+}
+
+func TestSseSubscriberReconnects(t *testing.T) {
+	var mu sync.Mutex
+	var lastIds []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastIds = append(lastIds, r.Header.Get("Last-Event-ID"))
+		connection := len(lastIds)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Every connection sends two events and ends the stream, which the subscriber resumes.
+		fmt.Fprintf(w, "id: %d\ndata: a\n\nid: %d\ndata: b\n\n", 2*connection-1, 2*connection)
+	}))
+	defer server.Close()
+
+	result := holdSubscriber(t, server.URL, 300*time.Millisecond, 20*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lastIds) < 3 {
+		t.Fatalf("subscriber connected %d times in 300ms, want at least 3", len(lastIds))
+	}
+	if result.Reconnects != len(lastIds)-1 {
+		t.Errorf("Reconnects = %d, want %d after %d connections", result.Reconnects, len(lastIds)-1, len(lastIds))
+	}
+	for i, got := range lastIds {
+		want := ""
+		if i > 0 {
+			want = fmt.Sprint(2 * i)
+		}
+		if got != want {
+			t.Errorf("connection %d sent Last-Event-ID %q, want %q", i+1, got, want)
+		}
+	}
+	if !result.Successful || result.Events != 2*len(lastIds) || result.MissedEvents != 0 {
+		t.Errorf("result = successful %v, %d events, %d missed, want successful, %d events, none missed",
+			result.Successful, result.Events, result.MissedEvents, 2*len(lastIds))
+	}
+}
+
+func TestSseSubscriberBacksOffFailedConnections(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections++
+		mu.Unlock()
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	result := holdSubscriber(t, server.URL, 350*time.Millisecond, 0)
+	mu.Lock()
+	defer mu.Unlock()
+	if max := int(350*time.Millisecond/sseMinFailedReconnect) + 1; connections > max {
+		t.Errorf("subscriber connected %d times in 350ms without a reconnect delay, want at most %d", connections, max)
+	}
+	if result.Successful || result.Status != http.StatusServiceUnavailable {
+		t.Errorf("result = successful %v, status %d, want a failure with status 503", result.Successful, result.Status)
+	}
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// phaseTrace times the phases of one attempt through httptrace hooks, which may be called from
+// other goroutines than the one sending the request.
+type phaseTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	phases       stats.Phases
+}
+
+// newPhaseTrace starts timing an attempt, requests must be sent with the returned context.
+func newPhaseTrace(ctx context.Context) (*phaseTrace, context.Context) {
+	t := &phaseTrace{start: time.Now()}
+	return t, httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phases.Reused = info.Reused
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phases.Dns = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStart.IsZero() { // dialing several addresses counts from the first one
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil {
+				t.phases.Connect = time.Since(t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phases.Tls = time.Since(t.tlsStart)
+			t.phases.Resumed = err == nil && state.DidResume
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.wrote = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.firstByte = time.Now()
+		},
+	})
+}
+
+// done returns the phases of the attempt once its response body was read, nil when no response arrived.
+func (t *phaseTrace) done() *stats.Phases {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstByte.IsZero() {
+		return nil
+	}
+	phases := t.phases
+	phases.Ttfb = t.firstByte.Sub(t.start)
+	if !t.wrote.IsZero() {
+		phases.Wait = t.firstByte.Sub(t.wrote)
+	}
+	phases.Transfer = time.Since(t.firstByte)
+	return &phases
+}
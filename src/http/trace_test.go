@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"generator/load/src/retry"
+)
+
+func TestPhaseTrace(t *testing.T) {
+	const serverTime = 20 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(serverTime)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(serverTime) // the rest of the body is the transfer phase
+		io.WriteString(w, "done")
+	}))
+	defer server.Close()
+	client := GenerateHttpReq(server.URL, "", 1, 1, http.MethodGet, 5, retry.Policy{}, 0, 0, nil).generateClient(false)
+
+	tests := []struct {
+		name   string
+		reused bool
+	}{
+		{"new connection", false},
+		{"kept alive", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, ctx := newPhaseTrace(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			phases := trace.done()
+			if phases == nil {
+				t.Fatal("done() = nil after a response")
+			}
+			if phases.Reused != tt.reused {
+				t.Errorf("Reused = %v, want %v", phases.Reused, tt.reused)
+			}
+			if (phases.Connect > 0) == tt.reused {
+				t.Errorf("Connect = %v on a connection reused %v", phases.Connect, tt.reused)
+			}
+			if phases.Dns != 0 || phases.Tls != 0 {
+				t.Errorf("Dns = %v, Tls = %v, want none for a plaintext IP address", phases.Dns, phases.Tls)
+			}
+			if phases.Wait < serverTime || phases.Ttfb < phases.Wait+phases.Connect {
+				t.Errorf("Wait = %v, Ttfb = %v, want the server time of %v within the time to first byte", phases.Wait, phases.Ttfb, serverTime)
+			}
+			if phases.Transfer < serverTime {
+				t.Errorf("Transfer = %v, want at least %v", phases.Transfer, serverTime)
+			}
+		})
+	}
+}
+
+func TestPhaseTraceNoResponse(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	trace, ctx := newPhaseTrace(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	if phases := trace.done(); phases != nil {
+		t.Errorf("done() = %+v without a response, want nil", phases)
+	}
+}
+
+func TestGenericLoadPhases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	h := GenerateHttpReq(server.URL, "", 4, 1, http.MethodGet, 5, retry.Policy{}, 0, 0, nil)
+	summary := runLoad(t, h, (*HttpReq).GenerateGenericLoad)
+	phases := summary.Phases
+	if phases.Traced != 4 || phases.Reused != 3 || phases.ConnectCount != 1 || phases.TlsCount != 0 {
+		t.Errorf("phases = %d traced, %d reused, %d connects, %d TLS handshakes, want 4, 3, 1, 0",
+			phases.Traced, phases.Reused, phases.ConnectCount, phases.TlsCount)
+	}
+}
+
+func TestGenericLoadTlsResumption(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close") // every request opens a connection with a new handshake
+	}))
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	tests := []struct {
+		name    string
+		cache   tls.ClientSessionCache
+		resumed int
+	}{
+		{"session cache", tls.NewLRUClientSessionCache(0), 3},
+		{"no session cache", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := GenerateHttpReq(server.URL, "", 4, 1, http.MethodGet, 5, retry.Policy{}, 0, 0, nil)
+			h.SetTls(&tls.Config{RootCAs: roots, ClientSessionCache: tt.cache})
+			summary := runLoad(t, h, (*HttpReq).GenerateGenericLoad)
+			phases := summary.Phases
+			if summary.Successful != 4 || phases.TlsCount != 4 || phases.Reused != 0 {
+				t.Fatalf("%d successful, %d TLS handshakes, %d reused connections, want 4, 4, 0",
+					summary.Successful, phases.TlsCount, phases.Reused)
+			}
+			if phases.Resumed != tt.resumed {
+				t.Errorf("%d of %d handshakes resumed, want %d", phases.Resumed, phases.TlsCount, tt.resumed)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTP versions the client may use, chosen with --http2, --h2c and --http1-only.
+const (
+	VersionAuto  = "auto"  // HTTP/2 when the server offers it over TLS, HTTP/1.1 otherwise
+	VersionHttp1 = "http1" // HTTP/1.1 only, even when the server offers HTTP/2
+	VersionHttp2 = "http2" // HTTP/2 over TLS only
+	VersionH2c   = "h2c"   // HTTP/2 over plaintext, assuming the server speaks it (prior knowledge)
+)
+
+// SetVersion limits the client to one of the Version* HTTP versions.
+func (h *HttpReq) SetVersion(version string) {
+	h.version = version
+}
+
+// SetTls sets the TLS configuration of the client, nil keeps the defaults.
+func (h *HttpReq) SetTls(config *tls.Config) {
+	h.tlsConfig = config
+}
+
+// transport has the settings of http.DefaultTransport, limited to the HTTP versions of the run. It
+// isn't cloned from it: the clone would inherit its TLS config offering h2 whatever the versions.
+func (h *HttpReq) transport() *http.Transport {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       h.tlsConfig,
+	}
+	protocols := new(http.Protocols)
+	switch h.version {
+	case VersionHttp1:
+		protocols.SetHTTP1(true)
+	case VersionHttp2:
+		protocols.SetHTTP2(true)
+	case VersionH2c:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return transport
+	}
+	transport.Protocols = protocols
+	return transport
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"generator/load/src/retry"
+)
+
+func TestTransportVersion(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+	// plaintext speaks HTTP/1.1 and HTTP/2 with prior knowledge.
+	plaintext := httptest.NewUnstartedServer(handler)
+	plaintext.Config.Protocols = new(http.Protocols)
+	plaintext.Config.Protocols.SetHTTP1(true)
+	plaintext.Config.Protocols.SetUnencryptedHTTP2(true)
+	plaintext.Start()
+	defer plaintext.Close()
+	// tlsServer offers HTTP/2 and HTTP/1.1 with ALPN, tls1Server only HTTP/1.1.
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+	tls1Server := httptest.NewTLSServer(handler)
+	defer tls1Server.Close()
+
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		version string
+		want    string // protocol the server saw, empty when the request must fail
+	}{
+		{"auto plaintext", plaintext, VersionAuto, "HTTP/1.1"},
+		{"http1 plaintext", plaintext, VersionHttp1, "HTTP/1.1"},
+		{"h2c plaintext", plaintext, VersionH2c, "HTTP/2.0"},
+		{"auto TLS", tlsServer, VersionAuto, "HTTP/2.0"},
+		{"http1 TLS", tlsServer, VersionHttp1, "HTTP/1.1"},
+		{"http2 TLS", tlsServer, VersionHttp2, "HTTP/2.0"},
+		{"auto TLS without HTTP/2", tls1Server, VersionAuto, "HTTP/1.1"},
+		{"http2 TLS without HTTP/2", tls1Server, VersionHttp2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := GenerateHttpReq(tt.server.URL, "", 1, 1, http.MethodGet, 5, retry.Policy{}, 0, 0, nil)
+			h.SetVersion(tt.version)
+			if tt.server.TLS != nil {
+				roots := x509.NewCertPool()
+				roots.AddCert(tt.server.Certificate())
+				h.SetTls(&tls.Config{RootCAs: roots})
+			}
+			resp, err := h.generateClient(false).Get(tt.server.URL)
+			if tt.want == "" {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("request succeeded with %s, want a failure", resp.Proto)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			seen, _ := io.ReadAll(resp.Body)
+			if string(seen) != tt.want || resp.Proto != tt.want {
+				t.Errorf("server saw %s, client got %s, want %s", seen, resp.Proto, tt.want)
+			}
+		})
+	}
+}
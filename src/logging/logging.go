@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+const (
+	LevelQuiet   = "quiet"   // only errors that stop the run
+	LevelDefault = "default" // plus warnings and sampled request failures
+	LevelVerbose = "verbose" // plus the progress of the run: start, sinks, finish
+	LevelDebug   = "debug"   // plus a line per request
+)
+
+// Setup installs the default slog logger, writing text records to out at the given level.
+func Setup(level string, out io.Writer) error {
+	var threshold slog.Level
+	switch level {
+	case LevelQuiet:
+		threshold = slog.LevelError
+	case LevelDefault:
+		threshold = slog.LevelWarn
+	case LevelVerbose:
+		threshold = slog.LevelInfo
+	case LevelDebug:
+		threshold = slog.LevelDebug
+	default:
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	output.set(out)
+	slog.SetDefault(slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: threshold})))
+	return nil
+}
+
+// Redirect sends the log records to w instead, until the returned function restores the output
+// given to Setup. It lets a dashboard hold the records back while it redraws the terminal.
+func Redirect(w io.Writer) (restore func()) {
+	previous := output.set(w)
+	return func() { output.set(previous) }
+}
+
+// output is the writer of the default logger, swapped by Redirect.
+var output = &switchWriter{}
+
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) set(w io.Writer) io.Writer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.w
+	s.w = w
+	return previous
+}
+
+func (s *switchWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return len(b), nil
+	}
+	return s.w.Write(b)
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	tests := []struct {
+		level   string
+		want    []string // levels of the records written, out of one of each
+		wantErr bool
+	}{
+		{LevelQuiet, []string{"ERROR"}, false},
+		{LevelDefault, []string{"WARN", "ERROR"}, false},
+		{LevelVerbose, []string{"INFO", "WARN", "ERROR"}, false},
+		{LevelDebug, []string{"DEBUG", "INFO", "WARN", "ERROR"}, false},
+		{"", nil, true},
+		{"Verbose", nil, true},
+		{"trace", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Setup(tt.level, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup(%q) error = %v, want error %v", tt.level, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			slog.Debug("d")
+			slog.Info("i")
+			slog.Warn("w")
+			slog.Error("e")
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				for _, field := range strings.Fields(line) {
+					if level, ok := strings.CutPrefix(field, "level="); ok {
+						got = append(got, level)
+					}
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Setup(%q) logged %v, want %v", tt.level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var out, held bytes.Buffer
+	if err := Setup(LevelDefault, &out); err != nil {
+		t.Fatal(err)
+	}
+	restore := Redirect(&held)
+	slog.Warn("while redirected")
+	restore()
+	slog.Warn("after restore")
+
+	if !strings.Contains(held.String(), "while redirected") || strings.Contains(held.String(), "after restore") {
+		t.Errorf("redirected output = %q, want only the record written while redirected", held.String())
+	}
+	if !strings.Contains(out.String(), "after restore") || strings.Contains(out.String(), "while redirected") {
+		t.Errorf("output = %q, want only the record written after restore", out.String())
+	}
+}
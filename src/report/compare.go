@@ -0,0 +1,278 @@
+package report
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"generator/load/src/stats"
+)
+
+// ReadJson reads a run summary written by WriteJson, rejecting summaries of a newer schema and
+// upgrading older ones.
+func ReadJson(r io.Reader) (*JsonReport, error) {
+	var report JsonReport
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, err
+	}
+	if report.Tool != "lgen" {
+		return nil, fmt.Errorf("not an lgen JSON summary")
+	}
+	if report.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("JSON summary schema version %d is newer than the supported version %d", report.SchemaVersion, SchemaVersion)
+	}
+	if report.SchemaVersion < 2 {
+		report.Config.MaxRetries = max(report.Config.MaxRetries-1, 0)
+	}
+	return &report, nil
+}
+
+func ReadJsonFile(path string) (*JsonReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	report, err := ReadJson(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
+
+// Tolerances are how much worse the new run may be than the baseline before it counts as a regression.
+type Tolerances struct {
+	Throughput   float64 // relative throughput drop, 0.05 allows 5% fewer requests per second
+	Latency      float64 // relative latency increase of the mean and percentiles
+	LatencyFloor float64 // milliseconds, latency increases below it are never regressions
+	ErrorRate    float64 // absolute error rate increase, 0.01 allows one more failed request in a hundred
+}
+
+// Delta is the change of one metric between the baseline and the new run.
+type Delta struct {
+	Metric    string
+	Unit      string
+	Old       float64
+	New       float64
+	Checked   bool   // whether the metric can regress, false for informational rows
+	Evidence  string // yes, no or n/a: whether the change is statistically significant at 95%
+	Regressed bool
+}
+
+// Change is the relative change of the metric, 0 when the baseline is 0.
+func (d Delta) Change() float64 {
+	if d.Old == 0 {
+		return 0
+	}
+	return (d.New - d.Old) / d.Old
+}
+
+// Comparison is the outcome of comparing a run against a baseline run.
+type Comparison struct {
+	Old        *JsonReport
+	New        *JsonReport
+	Deltas     []Delta
+	Mismatches []string // configuration differences that make the runs hard to compare
+}
+
+func (c *Comparison) Regressed() bool {
+	for _, d := range c.Deltas {
+		if d.Regressed {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare computes the deltas between two runs. A metric regresses when the change is beyond its
+// tolerance and significant, the significance of throughput and latency is a Welch t-test over the
+// timeline buckets of both runs, of the error rate a two-proportion z-test. When a run has too few
+// buckets to test the tolerance decides alone.
+func Compare(old, current *JsonReport, tolerances Tolerances) *Comparison {
+	c := &Comparison{Old: old, New: current, Mismatches: configMismatches(old.Config, current.Config)}
+	oldBuckets, newBuckets := fullBuckets(old.Timeline), fullBuckets(current.Timeline)
+
+	throughput := Delta{Metric: "throughput", Unit: "rps", Old: old.ThroughputRps, New: current.ThroughputRps, Checked: true}
+	throughput.Evidence = welch(
+		bucketValues(oldBuckets, func(b JsonBucket) float64 { return b.ThroughputRps }),
+		bucketValues(newBuckets, func(b JsonBucket) float64 { return b.ThroughputRps }))
+	throughput.Regressed = throughput.Old > 0 && -throughput.Change() > tolerances.Throughput && throughput.Evidence != "no"
+	c.Deltas = append(c.Deltas, throughput)
+
+	latencies := []struct {
+		metric string
+		value  func(JsonLatency) float64
+	}{
+		{"mean", func(l JsonLatency) float64 { return l.Mean }},
+		{"p50", func(l JsonLatency) float64 { return l.P50 }},
+		{"p90", func(l JsonLatency) float64 { return l.P90 }},
+		{"p95", func(l JsonLatency) float64 { return l.P95 }},
+		{"p99", func(l JsonLatency) float64 { return l.P99 }},
+		{"p99.9", func(l JsonLatency) float64 { return l.P999 }},
+	}
+	for _, latency := range latencies {
+		d := Delta{Metric: latency.metric, Unit: "ms", Old: latency.value(old.LatencyMs), New: latency.value(current.LatencyMs), Checked: true}
+		d.Evidence = welch(
+			bucketValues(oldBuckets, func(b JsonBucket) float64 { return latency.value(b.LatencyMs) }),
+			bucketValues(newBuckets, func(b JsonBucket) float64 { return latency.value(b.LatencyMs) }))
+		d.Regressed = d.Old > 0 && d.Change() > tolerances.Latency && d.New-d.Old > tolerances.LatencyFloor && d.Evidence != "no"
+		c.Deltas = append(c.Deltas, d)
+	}
+	c.Deltas = append(c.Deltas, Delta{Metric: "max", Unit: "ms", Old: old.LatencyMs.Max, New: current.LatencyMs.Max, Evidence: "n/a"})
+
+	errorRate := Delta{Metric: "error_rate", Unit: "%", Old: old.Requests.ErrorRate, New: current.Requests.ErrorRate, Checked: true}
+	errorRate.Evidence = twoProportions(old.Requests.Failed, old.Requests.Total, current.Requests.Failed, current.Requests.Total)
+	errorRate.Regressed = errorRate.New-errorRate.Old > tolerances.ErrorRate && errorRate.Evidence != "no"
+	c.Deltas = append(c.Deltas, errorRate)
+
+	if old.Events.Total > 0 || current.Events.Total > 0 {
+		c.Deltas = append(c.Deltas, Delta{Metric: "events/request", Old: old.Events.PerRequest, New: current.Events.PerRequest, Evidence: "n/a"})
+	}
+	return c
+}
+
+// Print writes the comparison as a table, one row per metric.
+func (c *Comparison) Print(w io.Writer) {
+	fmt.Fprintf(w, "Baseline: %s %s %s, %d requests, %s\n", c.Old.Config.Protocol, c.Old.Config.Mode, c.Old.Config.Target,
+		c.Old.Requests.Total, c.Old.StartedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(w, "New:      %s %s %s, %d requests, %s\n", c.New.Config.Protocol, c.New.Config.Mode, c.New.Config.Target,
+		c.New.Requests.Total, c.New.StartedAt.Format("2006-01-02 15:04:05 MST"))
+	for _, mismatch := range c.Mismatches {
+		fmt.Fprintf(w, "Warning: %s\n", mismatch)
+	}
+
+	fmt.Fprintf(w, "\n%-15s %14s %14s %10s %12s  %s\n", "metric", "baseline", "new", "change", "significant", "result")
+	for _, d := range c.Deltas {
+		var change string
+		if d.Unit == "%" {
+			change = fmt.Sprintf("%+.2fpt", (d.New-d.Old)*100)
+		} else if d.Old == 0 {
+			change = "n/a"
+		} else {
+			change = fmt.Sprintf("%+.1f%%", d.Change()*100)
+		}
+		result := "-"
+		if d.Regressed {
+			result = "REGRESSION"
+		} else if d.Checked {
+			result = "ok"
+		}
+		fmt.Fprintf(w, "%-15s %14s %14s %10s %12s  %s\n", d.Metric, formatValue(d.Old, d.Unit), formatValue(d.New, d.Unit), change, d.Evidence, result)
+	}
+}
+
+func formatValue(value float64, unit string) string {
+	switch unit {
+	case "%":
+		return fmt.Sprintf("%.2f%%", value*100)
+	case "":
+		return fmt.Sprintf("%.2f", value)
+	}
+	return fmt.Sprintf("%.2f %s", value, unit)
+}
+
+func configMismatches(old, current stats.RunConfig) []string {
+	var mismatches []string
+	if old.Protocol != current.Protocol || old.Mode != current.Mode {
+		mismatches = append(mismatches, fmt.Sprintf("mode differs: %s %s vs %s %s", old.Protocol, old.Mode, current.Protocol, current.Mode))
+	}
+	if old.HttpVersion != current.HttpVersion {
+		mismatches = append(mismatches, fmt.Sprintf("HTTP version differs: %s vs %s", cmp.Or(old.HttpVersion, "auto"), cmp.Or(current.HttpVersion, "auto")))
+	}
+	if old.Target != current.Target || old.Method != current.Method {
+		mismatches = append(mismatches, fmt.Sprintf("target differs: %s %s vs %s %s", old.Method, old.Target, current.Method, current.Target))
+	}
+	if old.Rate != current.Rate || old.Concurrency != current.Concurrency {
+		mismatches = append(mismatches, fmt.Sprintf("load differs: rate %g, concurrency %d vs rate %g, concurrency %d",
+			old.Rate, old.Concurrency, current.Rate, current.Concurrency))
+	}
+	return mismatches
+}
+
+// fullBuckets drops the last bucket of a timeline, the run usually ends part way through it.
+func fullBuckets(timeline []JsonBucket) []JsonBucket {
+	if len(timeline) < 3 {
+		return timeline
+	}
+	return timeline[:len(timeline)-1]
+}
+
+func bucketValues(buckets []JsonBucket, value func(JsonBucket) float64) []float64 {
+	values := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if b.Requests > 0 {
+			values = append(values, value(b))
+		}
+	}
+	return values
+}
+
+// welch reports whether the means of two samples differ at 95% confidence with Welch's t-test.
+func welch(a, b []float64) string {
+	if len(a) < 2 || len(b) < 2 {
+		return "n/a"
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	seA, seB := varA/float64(len(a)), varB/float64(len(b))
+	if seA+seB == 0 {
+		if meanA == meanB {
+			return "no"
+		}
+		return "yes"
+	}
+	t := math.Abs(meanA-meanB) / math.Sqrt(seA+seB)
+	df := (seA + seB) * (seA + seB) / (seA*seA/float64(len(a)-1) + seB*seB/float64(len(b)-1))
+	if t > tCritical(df) {
+		return "yes"
+	}
+	return "no"
+}
+
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64 = 0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64 = 0
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values)-1)
+}
+
+// tCriticalTable holds the two-sided 95% critical values of Student's t for 1 to 30 degrees of freedom.
+var tCriticalTable = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+func tCritical(df float64) float64 {
+	if df >= float64(len(tCriticalTable)) {
+		return 1.96
+	}
+	// Rounding the degrees of freedom down keeps the test conservative.
+	return tCriticalTable[max(int(df), 1)-1]
+}
+
+// twoProportions reports whether two failure rates differ at 95% confidence with a pooled z-test.
+func twoProportions(failedA, totalA, failedB, totalB int) string {
+	if totalA == 0 || totalB == 0 {
+		return "n/a"
+	}
+	pooled := float64(failedA+failedB) / float64(totalA+totalB)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(totalA) + 1/float64(totalB)))
+	if se == 0 {
+		return "no"
+	}
+	z := math.Abs(float64(failedA)/float64(totalA)-float64(failedB)/float64(totalB)) / se
+	if z > 1.96 {
+		return "yes"
+	}
+	return "no"
+}
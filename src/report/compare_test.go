@@ -0,0 +1,135 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWelch(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want string
+	}{
+		{"too few samples", []float64{1}, []float64{1, 2, 3}, "n/a"},
+		{"identical constants", []float64{5, 5, 5}, []float64{5, 5, 5, 5}, "no"},
+		{"different constants", []float64{5, 5, 5}, []float64{6, 6, 6}, "yes"},
+		{"clear shift", []float64{100, 101, 99, 100, 102}, []float64{150, 149, 151, 150, 148}, "yes"},
+		{"noise", []float64{80, 120, 90, 110, 100}, []float64{60, 240, 90, 210, 150}, "no"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := welch(tt.a, tt.b); got != tt.want {
+				t.Errorf("welch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwoProportions(t *testing.T) {
+	tests := []struct {
+		name                             string
+		failedA, totalA, failedB, totalB int
+		want                             string
+	}{
+		{"empty run", 0, 0, 5, 100, "n/a"},
+		{"no failures", 0, 100, 0, 100, "no"},
+		{"all failed", 100, 100, 100, 100, "no"},
+		{"jump", 0, 1000, 50, 1000, "yes"},
+		{"one more", 10, 1000, 12, 1000, "no"},
+		{"small runs", 1, 10, 3, 10, "no"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := twoProportions(tt.failedA, tt.totalA, tt.failedB, tt.totalB); got != tt.want {
+				t.Errorf("twoProportions(%d/%d, %d/%d) = %q, want %q", tt.failedA, tt.totalA, tt.failedB, tt.totalB, got, tt.want)
+			}
+		})
+	}
+}
+
+// compareRun builds a summary from its per-second throughput and latencies, every latency
+// percentile of a bucket being the same. A partial last bucket is added, Compare ignores it.
+func compareRun(throughput []float64, latency []float64, failed int, total int) *JsonReport {
+	r := &JsonReport{Tool: "lgen", SchemaVersion: SchemaVersion}
+	var rps, ms float64 = 0, 0
+	for i := range throughput {
+		l := JsonLatency{Mean: latency[i], P50: latency[i], P90: latency[i], P95: latency[i], P99: latency[i], P999: latency[i]}
+		r.Timeline = append(r.Timeline, JsonBucket{Requests: int(throughput[i]), ThroughputRps: throughput[i], LatencyMs: l})
+		rps += throughput[i] / float64(len(throughput))
+		ms += latency[i] / float64(len(latency))
+	}
+	r.Timeline = append(r.Timeline, JsonBucket{Requests: 1, ThroughputRps: 1, LatencyMs: JsonLatency{P99: 10000}})
+	r.ThroughputRps = rps
+	r.LatencyMs = JsonLatency{Mean: ms, P50: ms, P90: ms, P95: ms, P99: ms, P999: ms, Max: ms}
+	r.Requests = JsonRequests{Total: total, Failed: failed, ErrorRate: float64(failed) / float64(total)}
+	return r
+}
+
+func TestCompare(t *testing.T) {
+	tolerances := Tolerances{Throughput: 0.05, Latency: 0.1, LatencyFloor: 5, ErrorRate: 0.01}
+	steady := []float64{1000, 1001, 999, 1000, 1002}
+	fast := []float64{100, 101, 99, 100, 102}
+	baseline := compareRun(steady, fast, 0, 5000)
+
+	tests := []struct {
+		name      string
+		current   *JsonReport
+		regressed []string // metrics expected to regress
+	}{
+		{"same run", compareRun(steady, fast, 0, 5000), nil},
+		{"throughput drop", compareRun([]float64{800, 801, 799, 800, 802}, fast, 0, 4000), []string{"throughput"}},
+		{"throughput within tolerance", compareRun([]float64{970, 971, 969, 970, 972}, fast, 0, 4850), nil},
+		{"throughput rise", compareRun([]float64{1500, 1501, 1499, 1500, 1502}, fast, 0, 7500), nil},
+		{"latency increase", compareRun(steady, []float64{150, 149, 151, 150, 148}, 0, 5000),
+			[]string{"mean", "p50", "p90", "p95", "p99", "p99.9"}},
+		{"latency within tolerance", compareRun(steady, []float64{108, 109, 107, 108, 110}, 0, 5000), nil},
+		{"latency noise", compareRun(steady, []float64{60, 240, 90, 210, 150}, 0, 5000), nil},
+		{"error rate jump", compareRun(steady, fast, 250, 5000), []string{"error_rate"}},
+		{"error rate within tolerance", compareRun(steady, fast, 25, 5000), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Compare(baseline, tt.current, tolerances)
+			var regressed []string
+			for _, d := range c.Deltas {
+				if d.Regressed {
+					regressed = append(regressed, d.Metric)
+				}
+			}
+			if strings.Join(regressed, ",") != strings.Join(tt.regressed, ",") {
+				t.Errorf("regressed %v, want %v", regressed, tt.regressed)
+			}
+			if c.Regressed() != (len(tt.regressed) > 0) {
+				t.Errorf("Regressed() = %v, want %v", c.Regressed(), len(tt.regressed) > 0)
+			}
+		})
+	}
+}
+
+func TestReadJson(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       string
+		maxRetries int
+		wantErr    bool
+	}{
+		{"current schema", `{"tool":"lgen","schema_version":2,"config":{"max_retries":3}}`, 3, false},
+		{"attempts of schema 1", `{"tool":"lgen","schema_version":1,"config":{"max_retries":3}}`, 2, false},
+		{"single attempt of schema 1", `{"tool":"lgen","schema_version":1,"config":{"max_retries":0}}`, 0, false},
+		{"newer schema", `{"tool":"lgen","schema_version":99}`, 0, true},
+		{"other tool", `{"tool":"k6","schema_version":1}`, 0, true},
+		{"not JSON", `lgen`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ReadJson(strings.NewReader(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadJson() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && r.Config.MaxRetries != tt.maxRetries {
+				t.Errorf("max_retries = %d, want %d", r.Config.MaxRetries, tt.maxRetries)
+			}
+		})
+	}
+}
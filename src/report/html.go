@@ -0,0 +1,394 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"generator/load/src/stats"
+)
+
+const (
+	chartWidth   = 860
+	chartHeight  = 260
+	chartPadLeft = 64
+	chartPadTop  = 16
+	chartPadEnd  = 16
+	chartPadBase = 40
+)
+
+type chartSeries struct {
+	Name   string
+	Color  string
+	Values []float64
+}
+
+type htmlRow struct {
+	Name  string
+	Value string
+}
+
+type htmlPage struct {
+	Title      string
+	Config     []htmlRow
+	Totals     []htmlRow
+	Latency    template.HTML
+	Throughput template.HTML
+	Curve      template.HTML
+	Statuses   template.HTML
+	StatusRows []htmlRow
+	Errors     []htmlRow
+	Classes    []htmlErrorClass
+	Assertions []htmlRow
+	Phases     []htmlRow
+	Streams    []htmlRow
+	Sockets    []htmlRow
+	Thresholds []htmlThreshold
+}
+
+type htmlErrorClass struct {
+	Name    string
+	Count   int
+	Samples []string
+}
+
+type htmlThreshold struct {
+	Expression string
+	Actual     string
+	Passed     bool
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 32px auto; max-width: 900px; color: #1f2328; }
+h1 { font-size: 22px; } h2 { font-size: 17px; margin-top: 32px; }
+table { border-collapse: collapse; margin: 8px 0; }
+td { padding: 3px 16px 3px 0; border-bottom: 1px solid #eaeef2; }
+td:first-child { color: #59636e; }
+svg { background: #fafbfc; border: 1px solid #eaeef2; }
+svg text { font-size: 11px; fill: #59636e; }
+.legend span { display: inline-block; margin-right: 16px; font-size: 12px; }
+.pass { color: #1a7f37; font-weight: 600; } .fail { color: #cf222e; font-weight: 600; }
+.legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>Run configuration</h2>
+<table>{{range .Config}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>
+{{if .Thresholds}}<h2>Thresholds</h2>
+<table>{{range .Thresholds}}<tr><td>{{.Expression}}</td><td>{{.Actual}}</td><td>{{if .Passed}}<span class="pass">PASS</span>{{else}}<span class="fail">FAIL</span>{{end}}</td></tr>{{end}}</table>{{end}}
+<h2>Results</h2>
+<table>{{range .Totals}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>
+{{if .Phases}}<h2>Latency phases</h2>
+<table>{{range .Phases}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{if .Streams}}<h2>Streams</h2>
+<table>{{range .Streams}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{if .Sockets}}<h2>WebSocket</h2>
+<table>{{range .Sockets}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+<h2>Latency over time</h2>
+{{.Latency}}
+<h2>Throughput over time</h2>
+{{.Throughput}}
+<h2>Latency distribution</h2>
+{{.Curve}}
+<h2>Status codes</h2>
+{{.Statuses}}
+<table>{{range .StatusRows}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>
+{{if .Assertions}}<h2>Assertion failures</h2>
+<table>{{range .Assertions}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{if .Classes}}<h2>Errors</h2>
+<table>{{range .Classes}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>{{range .Samples}}<tr><td></td><td><code>{{.}}</code></td></tr>{{end}}{{end}}</table>{{end}}
+{{if .Errors}}<table>{{range .Errors}}<tr><td>{{.Value}}</td><td>{{.Name}}</td></tr>{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// WriteHtml writes a single file report of the run with inline SVG charts, viewable without network access.
+func WriteHtml(w io.Writer, s *stats.Summary) error {
+	offsets := make([]string, len(s.Timeline))
+	meanLatency := make([]float64, len(s.Timeline))
+	p99Latency := make([]float64, len(s.Timeline))
+	maxLatency := make([]float64, len(s.Timeline))
+	throughput := make([]float64, len(s.Timeline))
+	errors := make([]float64, len(s.Timeline))
+	for i, b := range s.Timeline {
+		offsets[i] = fmt.Sprintf("%gs", b.Offset.Seconds())
+		meanLatency[i] = milliseconds(b.Latency.Mean)
+		p99Latency[i] = milliseconds(b.Latency.P99)
+		maxLatency[i] = milliseconds(b.Latency.Max)
+		throughput[i] = b.Throughput(s.Interval)
+		errors[i] = float64(b.Errors) / s.Interval.Seconds()
+	}
+
+	percentiles := make([]string, len(s.LatencyCurve))
+	curve := make([]float64, len(s.LatencyCurve))
+	for i, point := range s.LatencyCurve {
+		percentiles[i] = fmt.Sprintf("p%g", point.Percentile)
+		curve[i] = milliseconds(point.Latency)
+	}
+
+	codes := make([]int, 0, len(s.StatusCodes))
+	for code := range s.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	statusNames := make([]string, len(codes))
+	statusCounts := make([]float64, len(codes))
+	statusRows := make([]htmlRow, len(codes))
+	for i, code := range codes {
+		statusNames[i] = s.StatusName(code)
+		statusCounts[i] = float64(s.StatusCodes[code])
+		statusRows[i] = htmlRow{statusNames[i], fmt.Sprint(s.StatusCodes[code])}
+	}
+
+	messages := make([]string, 0, len(s.Errors))
+	for message := range s.Errors {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+	errorRows := make([]htmlRow, len(messages))
+	for i, message := range messages {
+		errorRows[i] = htmlRow{message, fmt.Sprint(s.Errors[message])}
+	}
+
+	classes := make([]htmlErrorClass, 0, len(s.ErrorClasses))
+	for _, class := range s.ErrorClassNames() {
+		c := htmlErrorClass{Name: class, Count: s.ErrorClasses[class]}
+		for _, sample := range s.ErrorSamples[class] {
+			text := sample.Error
+			if sample.Body != "" {
+				text += fmt.Sprintf(", body: %q", sample.Body)
+			}
+			c.Samples = append(c.Samples, text)
+		}
+		classes = append(classes, c)
+	}
+
+	expressions := make([]string, 0, len(s.AssertionFailures))
+	for expression := range s.AssertionFailures {
+		expressions = append(expressions, expression)
+	}
+	sort.Strings(expressions)
+	assertionRows := make([]htmlRow, len(expressions))
+	for i, expression := range expressions {
+		assertionRows[i] = htmlRow{expression, fmt.Sprint(s.AssertionFailures[expression])}
+	}
+
+	var phaseRows []htmlRow = nil
+	if s.Phases.Traced > 0 {
+		for _, row := range s.Phases.Rows() {
+			if row.Count > 0 {
+				phaseRows = append(phaseRows, htmlRow{row.Name, fmt.Sprintf("p50 %.2f / p90 %.2f / p99 %.2f ms over %d requests",
+					milliseconds(row.Latency.P50), milliseconds(row.Latency.P90), milliseconds(row.Latency.P99), row.Count)})
+			}
+		}
+		phaseRows = append(phaseRows, htmlRow{"connection reuse", fmt.Sprintf("%.2f%%", s.Phases.ReuseRate()*100)})
+		if s.Phases.TlsCount > 0 {
+			phaseRows = append(phaseRows, htmlRow{"TLS session resumption", fmt.Sprintf("%.2f%% (%d of %d handshakes)", s.Phases.ResumeRate()*100, s.Phases.Resumed, s.Phases.TlsCount)})
+		}
+	}
+
+	var streamRows []htmlRow = nil
+	if s.Streams.Streams > 0 {
+		for _, row := range []struct {
+			name    string
+			latency stats.Distribution
+		}{{"first byte", s.Streams.FirstByte}, {"first event", s.Streams.FirstEvent}, {"inter-event gap", s.Streams.Gap}} {
+			streamRows = append(streamRows, htmlRow{row.name, fmt.Sprintf("p50 %.2f / p90 %.2f / p99 %.2f ms",
+				milliseconds(row.latency.P50), milliseconds(row.latency.P90), milliseconds(row.latency.P99))})
+		}
+		rate := s.Streams.EventRate
+		streamRows = append(streamRows, htmlRow{"events per second per stream", fmt.Sprintf("p50 %.2f / p90 %.2f / p99 %.2f", rate.P50, rate.P90, rate.P99)})
+	}
+	if s.Config.HoldSeconds > 0 {
+		streamRows = append(streamRows, htmlRow{"reconnects / missed events", fmt.Sprintf("%d / %d", s.Reconnects, s.MissedEvents)})
+	}
+
+	var socketRows []htmlRow = nil
+	if s.Sockets.Connections > 0 {
+		for _, row := range []struct {
+			name    string
+			latency stats.Distribution
+		}{{"connect", s.Sockets.Connect}, {"round trip", s.Sockets.RoundTrip}} {
+			socketRows = append(socketRows, htmlRow{row.name, fmt.Sprintf("p50 %.2f / p90 %.2f / p99 %.2f ms",
+				milliseconds(row.latency.P50), milliseconds(row.latency.P90), milliseconds(row.latency.P99))})
+		}
+		socketRows = append(socketRows, htmlRow{"messages sent / received / unanswered", fmt.Sprintf("%d / %d / %d", s.Sockets.Sent, s.Sockets.Received, s.Sockets.Unanswered)})
+		for _, code := range s.Sockets.CloseCodeNames() {
+			socketRows = append(socketRows, htmlRow{fmt.Sprintf("close code %d", code), fmt.Sprint(s.Sockets.CloseCodes[code])})
+		}
+	}
+
+	thresholds := make([]htmlThreshold, len(s.Thresholds))
+	for i, r := range s.Thresholds {
+		thresholds[i] = htmlThreshold{r.Expression, r.FormatActual(), r.Passed}
+	}
+
+	page := htmlPage{
+		Title: fmt.Sprintf("lgen report: %s %s %s", s.Config.Protocol, s.Config.Mode, s.Config.Target),
+		Config: []htmlRow{
+			{"Protocol", s.Config.Protocol},
+			{"Mode", s.Config.Mode},
+			{"Target", s.Config.Target},
+			{"Method", s.Config.Method},
+			{"Requests", fmt.Sprint(s.Config.Requests)},
+			{"Concurrency", fmt.Sprint(s.Config.Concurrency)},
+			{"Timeout", fmt.Sprintf("%ds", s.Config.Timeout)},
+			{"Max retries", fmt.Sprint(s.Config.MaxRetries)},
+			{"Rate", fmt.Sprintf("%g req/s", s.Config.Rate)},
+			{"Latency mode", s.Config.LatencyMode},
+			{"Started", s.Started.Format("2006-01-02 15:04:05 MST")},
+		},
+		Totals: []htmlRow{
+			{"Requests", fmt.Sprint(s.Requests)},
+			{"Success rate", fmt.Sprintf("%.2f%%", s.SuccessRate()*100)},
+			{"Duration", fmt.Sprintf("%.3fs", s.Duration.Seconds())},
+			{"Throughput", fmt.Sprintf("%.2f req/s", s.Throughput)},
+			{"Latency mean / p50 / p90 / p99 / max", fmt.Sprintf("%.2f / %.2f / %.2f / %.2f / %.2f ms",
+				milliseconds(s.Latency.Mean), milliseconds(s.Latency.P50), milliseconds(s.Latency.P90),
+				milliseconds(s.Latency.P99), milliseconds(s.Latency.Max))},
+			{"Retried requests / attempts per request", fmt.Sprintf("%d (%.2f%%) / %.3f", s.Retried, s.RetryRate()*100, s.AttemptsPerRequest())},
+			{"First try latency p50 / p99", fmt.Sprintf("%.2f / %.2f ms", milliseconds(s.FirstTryLatency.P50), milliseconds(s.FirstTryLatency.P99))},
+			{"Events", fmt.Sprint(s.Events)},
+			{"Bytes sent / received", fmt.Sprintf("%d / %d", s.BytesOut, s.BytesIn)},
+		},
+		Latency: lineChart("ms", offsets,
+			chartSeries{"mean", "#0969da", meanLatency},
+			chartSeries{"p99", "#9a6700", p99Latency},
+			chartSeries{"max", "#cf222e", maxLatency}),
+		Throughput: lineChart("req/s", offsets,
+			chartSeries{"completed", "#1a7f37", throughput},
+			chartSeries{"errors", "#cf222e", errors}),
+		Curve:      barChart("ms", percentiles, curve, "#8250df"),
+		Statuses:   barChart("requests", statusNames, statusCounts, "#0969da"),
+		StatusRows: statusRows,
+		Errors:     errorRows,
+		Classes:    classes,
+		Assertions: assertionRows,
+		Phases:     phaseRows,
+		Streams:    streamRows,
+		Sockets:    socketRows,
+		Thresholds: thresholds,
+	}
+	if s.Config.HttpVersion != "" {
+		page.Config = append(page.Config, htmlRow{"HTTP version", s.Config.HttpVersion})
+	}
+	if len(s.Protocols) > 0 {
+		page.Totals = append(page.Totals, htmlRow{"Negotiated protocols", stats.CountLine(s.Protocols)})
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+// lineChart renders series sharing the x labels as an SVG line chart with a legend.
+func lineChart(unit string, labels []string, series ...chartSeries) template.HTML {
+	var top float64 = 0
+	for _, s := range series {
+		for _, v := range s.Values {
+			top = math.Max(top, v)
+		}
+	}
+	var b strings.Builder
+	b.WriteString(`<div class="legend">`)
+	for _, s := range series {
+		fmt.Fprintf(&b, `<span><i style="background:%s"></i>%s</span>`, s.Color, template.HTMLEscapeString(s.Name))
+	}
+	b.WriteString(`</div>`)
+	top = chartAxes(&b, unit, labels, top, false)
+	for _, s := range series {
+		points := make([]string, len(s.Values))
+		for i, v := range s.Values {
+			points[i] = fmt.Sprintf("%.1f,%.1f", chartX(i, len(s.Values)), chartY(v, top))
+		}
+		if len(points) == 1 {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`, chartX(0, 1), chartY(s.Values[0], top), s.Color)
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, s.Color, strings.Join(points, " "))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// barChart renders one bar per label as an SVG bar chart.
+func barChart(unit string, labels []string, values []float64, color string) template.HTML {
+	var top float64 = 0
+	for _, v := range values {
+		top = math.Max(top, v)
+	}
+	var b strings.Builder
+	top = chartAxes(&b, unit, labels, top, true)
+	plotWidth := float64(chartWidth - chartPadLeft - chartPadEnd)
+	slot := plotWidth / math.Max(float64(len(values)), 1)
+	for i, v := range values {
+		x := float64(chartPadLeft) + float64(i)*slot + slot*0.15
+		y := chartY(v, top)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %g</title></rect>`,
+			x, y, slot*0.7, float64(chartHeight-chartPadBase)-y, color, template.HTMLEscapeString(labels[i]), v)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// chartAxes opens the SVG element and draws the grid, y axis values and (sampled) x labels,
+// placed under the points of a line chart or the middle of the bars of a bar chart,
+// returning the rounded top of the y axis.
+func chartAxes(b *strings.Builder, unit string, labels []string, top float64, bars bool) float64 {
+	top = niceTop(top)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	for i := 0; i <= 4; i++ {
+		value := top * float64(i) / 4
+		y := chartY(value, top)
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eaeef2"/>`, chartPadLeft, y, chartWidth-chartPadEnd, y)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%g</text>`, chartPadLeft-6, y+4, value)
+	}
+	fmt.Fprintf(b, `<text x="8" y="%d">%s</text>`, chartPadTop, template.HTMLEscapeString(unit))
+	step := int(math.Ceil(float64(len(labels)) / 12))
+	if step < 1 {
+		step = 1
+	}
+	plotWidth := float64(chartWidth - chartPadLeft - chartPadEnd)
+	slot := plotWidth / math.Max(float64(len(labels)), 1)
+	for i := 0; i < len(labels); i += step {
+		x := chartX(i, len(labels))
+		if bars {
+			x = float64(chartPadLeft) + float64(i)*slot + slot/2
+		}
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, chartHeight-chartPadBase+16, template.HTMLEscapeString(labels[i]))
+	}
+	return top
+}
+
+func chartX(i int, n int) float64 {
+	plotWidth := float64(chartWidth - chartPadLeft - chartPadEnd)
+	if n <= 1 {
+		return float64(chartPadLeft) + plotWidth/2
+	}
+	return float64(chartPadLeft) + plotWidth*float64(i)/float64(n-1)
+}
+
+func chartY(v float64, top float64) float64 {
+	plotHeight := float64(chartHeight - chartPadTop - chartPadBase)
+	return float64(chartPadTop) + plotHeight*(1-v/top)
+}
+
+// niceTop rounds the maximum of a chart up to 1, 2 or 5 times a power of ten.
+func niceTop(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
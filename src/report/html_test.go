@@ -0,0 +1,91 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// externalReference matches what would make a browser fetch something to show the report.
+var externalReference = regexp.MustCompile(`(?i)<(script|link|img|iframe|object|embed)\b|\s(src|href)\s*=\s*["']|url\(|@import`)
+
+var anyUrl = regexp.MustCompile(`https?://[^\s"'<>]*`)
+
+func TestWriteHtmlSelfContained(t *testing.T) {
+	target := "https://api.example.com/items?q=<b>&page=2"
+	start := time.Now()
+	summary := testSummary(t, stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: stats.ModeUnary, Target: target},
+		stats.Result{Start: start, End: start.Add(10 * time.Millisecond), Successful: true, Status: 200},
+		stats.Result{Start: start, End: start.Add(20 * time.Millisecond), Status: 500, ErrorClass: stats.ErrorHttp5xx,
+			Error: "500 Internal Server Error", Body: `<script src="https://cdn.example.com/x.js"></script>`},
+	)
+	var out bytes.Buffer
+	if err := WriteHtml(&out, summary); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+
+	if !strings.Contains(page, "<svg") {
+		t.Error("report has no inline SVG chart")
+	}
+	if match := externalReference.FindString(page); match != "" {
+		t.Errorf("report references external content with %q", match)
+	}
+	// The target and the failed response body are shown escaped, and the SVG namespace is never fetched.
+	rest := strings.ReplaceAll(page, template.HTMLEscapeString(target), "")
+	rest = strings.ReplaceAll(rest, `xmlns="http://www.w3.org/2000/svg"`, "")
+	for _, url := range anyUrl.FindAllString(rest, -1) {
+		if !strings.HasPrefix(url, "https://cdn.example.com/x.js") {
+			t.Errorf("report contains the URL %s", url)
+		}
+	}
+	if strings.Contains(page, "<script src=") || !strings.Contains(page, "&lt;script") {
+		t.Error("failed response body is not escaped")
+	}
+}
+
+func TestWriteHtmlSections(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name    string
+		results []stats.Result
+		want    []string
+		notWant []string
+	}{
+		{
+			"successful run",
+			[]stats.Result{{Start: start, End: start.Add(time.Millisecond), Successful: true, Status: 200}},
+			[]string{"<h2>Results</h2>", "<h2>Latency over time</h2>", "<h2>Status codes</h2>"},
+			[]string{"<h2>Errors</h2>", "<h2>Thresholds</h2>", "<h2>Streams</h2>", "<h2>WebSocket</h2>"},
+		},
+		{
+			"failed assertion",
+			[]stats.Result{{Start: start, End: start.Add(time.Millisecond), Status: 200, ErrorClass: stats.ErrorAssertion, Assertion: "body contains ok"}},
+			[]string{"<h2>Assertion failures</h2>", "body contains ok", "<h2>Errors</h2>"},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteHtml(&out, testSummary(t, stats.RunConfig{Protocol: stats.ProtocolHttp}, tt.results...)); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("report misses %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("report has %q", notWant)
+				}
+			}
+		})
+	}
+}
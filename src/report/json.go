@@ -0,0 +1,340 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// SchemaVersion is bumped whenever a field of JsonReport is renamed, removed or changes meaning,
+// adding fields keeps the version. Version 2 counts config.max_retries as retries after the first
+// attempt instead of attempts.
+const SchemaVersion = 2
+
+// JsonReport is the machine readable summary of a run.
+type JsonReport struct {
+	SchemaVersion   int                          `json:"schema_version"`
+	Tool            string                       `json:"tool"`
+	Config          stats.RunConfig              `json:"config"`
+	StartedAt       time.Time                    `json:"started_at"`
+	FinishedAt      time.Time                    `json:"finished_at"`
+	DurationSeconds float64                      `json:"duration_seconds"`
+	Requests        JsonRequests                 `json:"requests"`
+	ThroughputRps   float64                      `json:"throughput_rps"`
+	LatencyMs       JsonLatency                  `json:"latency_ms"`
+	UncorrectedMs   JsonLatency                  `json:"uncorrected_latency_ms"`
+	Retries         JsonRetries                  `json:"retries"`
+	Phases          *JsonPhases                  `json:"phases,omitempty"`
+	Streams         *JsonStreams                 `json:"streams,omitempty"`
+	Websocket       *JsonWebsocket               `json:"websocket,omitempty"`
+	Events          JsonEvents                   `json:"events"`
+	Bytes           JsonBytes                    `json:"bytes"`
+	StatusCodes     map[string]int               `json:"status_codes"`
+	Protocols       map[string]int               `json:"protocols,omitempty"` // HTTP responses by negotiated version, e.g. HTTP/2.0
+	Errors          map[string]int               `json:"errors"`
+	ErrorClasses    map[string]int               `json:"error_classes"`
+	ErrorSamples    map[string][]JsonErrorSample `json:"error_samples"`
+	Assertions      map[string]int               `json:"assertion_failures"`
+	Stages          []JsonStage                  `json:"stages"`
+	IntervalSeconds float64                      `json:"interval_seconds"`
+	Timeline        []JsonBucket                 `json:"timeline"`
+	Thresholds      []JsonThreshold              `json:"thresholds,omitempty"`
+}
+
+type JsonRequests struct {
+	Total       int     `json:"total"`
+	Successful  int     `json:"successful"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+	Assertion   int     `json:"assertion_failed"` // failed an --assert check, included in Failed
+}
+
+type JsonLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p99_9"`
+	Max  float64 `json:"max"`
+}
+
+type JsonEvents struct {
+	Total      int            `json:"total"`
+	PerRequest float64        `json:"per_request"`
+	ByType     map[string]int `json:"by_type,omitempty"` // SSE events by event type
+	Missed     int            `json:"missed"`            // SSE events skipped according to their numeric ids
+	Reconnects int            `json:"reconnects"`        // reconnections of SSE subscribers held with --hold
+}
+
+// JsonRetries holds the retry accounting, first_try_latency_ms is the latency of the first attempts
+// as if nothing had been retried.
+type JsonRetries struct {
+	MaxRetries      int         `json:"max_retries"`
+	Attempts        int         `json:"attempts"`
+	PerRequest      float64     `json:"attempts_per_request"`
+	Retried         int         `json:"retried"`
+	RetryRate       float64     `json:"retry_rate"`
+	FirstTryLatency JsonLatency `json:"first_try_latency_ms"`
+}
+
+// JsonPhases breaks the latency of HTTP requests down, each phase with the number of requests it
+// happened for: connections are only opened by the requests that didn't reuse one.
+type JsonPhases struct {
+	Traced        int         `json:"traced"`
+	Reused        int         `json:"reused"`
+	ReuseRate     float64     `json:"reuse_rate"`
+	TlsHandshakes int         `json:"tls_handshakes"`
+	TlsResumed    int         `json:"tls_resumed"` // handshakes that resumed an earlier TLS session
+	ResumeRate    float64     `json:"tls_resumption_rate"`
+	Phases        []JsonPhase `json:"phases"`
+}
+
+// JsonPhase holds the latency percentiles of one phase in milliseconds, named dns, connect, tls,
+// server, ttfb or transfer.
+type JsonPhase struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	JsonLatency
+}
+
+// JsonStreams holds the timings of SSE and server streams in milliseconds from when each stream was
+// opened, first_event_ms over the streams that received an event.
+type JsonStreams struct {
+	Streams         int          `json:"streams"`
+	FirstByteMs     JsonLatency  `json:"first_byte_ms"`
+	FirstEventMs    JsonLatency  `json:"first_event_ms"`
+	InterEventGapMs JsonLatency  `json:"inter_event_gap_ms"`
+	EventsPerSecond JsonRateDist `json:"events_per_second"`
+}
+
+// JsonRateDist holds the percentiles of a rate measured per stream.
+type JsonRateDist struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// JsonWebsocket holds the connections of a WebSocket run, round_trip_ms over the replies matched to
+// a message and close_codes by the code of the close frame received (1006 without one).
+type JsonWebsocket struct {
+	Connections int            `json:"connections"`
+	ConnectMs   JsonLatency    `json:"connect_ms"`
+	RoundTripMs JsonLatency    `json:"round_trip_ms"`
+	Sent        int            `json:"messages_sent"`
+	Received    int            `json:"messages_received"`
+	Unanswered  int            `json:"messages_unanswered"`
+	CloseCodes  map[string]int `json:"close_codes"`
+}
+
+type JsonBytes struct {
+	Sent     int64 `json:"sent"`
+	Received int64 `json:"received"`
+}
+
+// JsonErrorSample is one failed request of an error class, body holds the start of the response body.
+type JsonErrorSample struct {
+	Worker int    `json:"worker"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Body   string `json:"body,omitempty"`
+}
+
+// JsonStage holds the figures of one stage of the run, a run without stages reports a single "main" stage.
+type JsonStage struct {
+	Name            string       `json:"name"`
+	StartedAt       time.Time    `json:"started_at"`
+	DurationSeconds float64      `json:"duration_seconds"`
+	Requests        JsonRequests `json:"requests"`
+	ThroughputRps   float64      `json:"throughput_rps"`
+	LatencyMs       JsonLatency  `json:"latency_ms"`
+}
+
+// JsonBucket holds the requests that finished within one interval of the run, see interval_seconds.
+type JsonBucket struct {
+	OffsetSeconds float64     `json:"offset_seconds"`
+	Requests      int         `json:"requests"`
+	Errors        int         `json:"errors"`
+	ErrorRate     float64     `json:"error_rate"`
+	Events        int         `json:"events"`
+	ThroughputRps float64     `json:"throughput_rps"`
+	LatencyMs     JsonLatency `json:"latency_ms"`
+}
+
+// JsonThreshold is the outcome of one --threshold, actual is in the unit of the metric:
+// milliseconds for latencies, a fraction for rates.
+type JsonThreshold struct {
+	Expression string  `json:"expression"`
+	Metric     string  `json:"metric"`
+	Actual     float64 `json:"actual"`
+	Passed     bool    `json:"passed"`
+}
+
+func NewJsonReport(s *stats.Summary) *JsonReport {
+	requests := JsonRequests{
+		Total:       s.Requests,
+		Successful:  s.Successful,
+		Failed:      s.Failed,
+		SuccessRate: s.SuccessRate(),
+		ErrorRate:   s.ErrorRate(),
+		Assertion:   s.AssertionFailed(),
+	}
+	var perRequest float64 = 0
+	if s.Requests > 0 {
+		perRequest = float64(s.Events) / float64(s.Requests)
+	}
+	timeline := make([]JsonBucket, len(s.Timeline))
+	for i, b := range s.Timeline {
+		timeline[i] = JsonBucket{
+			OffsetSeconds: b.Offset.Seconds(),
+			Requests:      b.Requests,
+			Errors:        b.Errors,
+			ErrorRate:     b.ErrorRate(),
+			Events:        b.Events,
+			ThroughputRps: b.Throughput(s.Interval),
+			LatencyMs:     newJsonLatency(b.Latency),
+		}
+	}
+	var thresholds []JsonThreshold
+	for _, r := range s.Thresholds {
+		actual := r.Actual
+		if r.Latency() {
+			actual *= 1000
+		}
+		thresholds = append(thresholds, JsonThreshold{
+			Expression: r.Expression,
+			Metric:     r.Metric,
+			Actual:     actual,
+			Passed:     r.Passed,
+		})
+	}
+	samples := make(map[string][]JsonErrorSample, len(s.ErrorSamples))
+	for class, classSamples := range s.ErrorSamples {
+		for _, sample := range classSamples {
+			samples[class] = append(samples[class], JsonErrorSample{
+				Worker: sample.Worker,
+				Status: s.StatusName(sample.Status),
+				Error:  sample.Error,
+				Body:   sample.Body,
+			})
+		}
+	}
+	var phases *JsonPhases = nil
+	if s.Phases.Traced > 0 {
+		phases = &JsonPhases{
+			Traced:        s.Phases.Traced,
+			Reused:        s.Phases.Reused,
+			ReuseRate:     s.Phases.ReuseRate(),
+			TlsHandshakes: s.Phases.TlsCount,
+			TlsResumed:    s.Phases.Resumed,
+			ResumeRate:    s.Phases.ResumeRate(),
+		}
+		for _, row := range s.Phases.Rows() {
+			phases.Phases = append(phases.Phases, JsonPhase{Name: row.Name, Count: row.Count, JsonLatency: newJsonLatency(row.Latency)})
+		}
+	}
+	var streams *JsonStreams = nil
+	if s.Streams.Streams > 0 {
+		rate := s.Streams.EventRate
+		streams = &JsonStreams{
+			Streams:         s.Streams.Streams,
+			FirstByteMs:     newJsonLatency(s.Streams.FirstByte),
+			FirstEventMs:    newJsonLatency(s.Streams.FirstEvent),
+			InterEventGapMs: newJsonLatency(s.Streams.Gap),
+			EventsPerSecond: JsonRateDist{Min: rate.Min, Mean: rate.Mean, P50: rate.P50, P90: rate.P90, P99: rate.P99, Max: rate.Max},
+		}
+	}
+	var websocket *JsonWebsocket = nil
+	if s.Sockets.Connections > 0 {
+		closeCodes := make(map[string]int, len(s.Sockets.CloseCodes))
+		for code, count := range s.Sockets.CloseCodes {
+			closeCodes[strconv.Itoa(code)] = count
+		}
+		websocket = &JsonWebsocket{
+			Connections: s.Sockets.Connections,
+			ConnectMs:   newJsonLatency(s.Sockets.Connect),
+			RoundTripMs: newJsonLatency(s.Sockets.RoundTrip),
+			Sent:        s.Sockets.Sent,
+			Received:    s.Sockets.Received,
+			Unanswered:  s.Sockets.Unanswered,
+			CloseCodes:  closeCodes,
+		}
+	}
+	statuses := make(map[string]int, len(s.StatusCodes))
+	for code, count := range s.StatusCodes {
+		statuses[s.StatusName(code)] += count
+	}
+	return &JsonReport{
+		SchemaVersion:   SchemaVersion,
+		Tool:            "lgen",
+		Config:          s.Config,
+		StartedAt:       s.Started,
+		FinishedAt:      s.Finished,
+		DurationSeconds: s.Duration.Seconds(),
+		Requests:        requests,
+		ThroughputRps:   s.Throughput,
+		LatencyMs:       newJsonLatency(s.Latency),
+		UncorrectedMs:   newJsonLatency(s.UncorrectedLatency),
+		Retries: JsonRetries{
+			MaxRetries:      s.Config.MaxRetries,
+			Attempts:        s.Attempts,
+			PerRequest:      s.AttemptsPerRequest(),
+			Retried:         s.Retried,
+			RetryRate:       s.RetryRate(),
+			FirstTryLatency: newJsonLatency(s.FirstTryLatency),
+		},
+		Phases:       phases,
+		Streams:      streams,
+		Websocket:    websocket,
+		Events:       JsonEvents{Total: s.Events, PerRequest: perRequest, ByType: s.EventTypes, Missed: s.MissedEvents, Reconnects: s.Reconnects},
+		Bytes:        JsonBytes{Sent: s.BytesOut, Received: s.BytesIn},
+		StatusCodes:  statuses,
+		Protocols:    s.Protocols,
+		Errors:       s.Errors,
+		ErrorClasses: s.ErrorClasses,
+		ErrorSamples: samples,
+		Assertions:   s.AssertionFailures,
+		Stages: []JsonStage{{
+			Name:            "main",
+			StartedAt:       s.Started,
+			DurationSeconds: s.Duration.Seconds(),
+			Requests:        requests,
+			ThroughputRps:   s.Throughput,
+			LatencyMs:       newJsonLatency(s.Latency),
+		}},
+		IntervalSeconds: s.Interval.Seconds(),
+		Timeline:        timeline,
+		Thresholds:      thresholds,
+	}
+}
+
+func newJsonLatency(d stats.Distribution) JsonLatency {
+	return JsonLatency{
+		Min:  milliseconds(d.Min),
+		Mean: milliseconds(d.Mean),
+		P50:  milliseconds(d.P50),
+		P90:  milliseconds(d.P90),
+		P95:  milliseconds(d.P95),
+		P99:  milliseconds(d.P99),
+		P999: milliseconds(d.P999),
+		Max:  milliseconds(d.Max),
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func WriteJson(w io.Writer, s *stats.Summary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(NewJsonReport(s))
+}
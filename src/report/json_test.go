@@ -0,0 +1,114 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// testSummary summarizes a run of the given results, one succeeding 200 after 10ms when none are given.
+func testSummary(t *testing.T, config stats.RunConfig, results ...stats.Result) *stats.Summary {
+	t.Helper()
+	if len(results) == 0 {
+		start := time.Now()
+		results = []stats.Result{{Start: start, End: start.Add(10 * time.Millisecond), Successful: true, Status: 200}}
+	}
+	config.Requests = len(results)
+	collector := stats.NewCollector(config)
+	collector.Start()
+	for _, r := range results {
+		collector.Dispatch()
+		collector.Record(r)
+	}
+	summary, err := collector.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestWriteJson(t *testing.T) {
+	start := time.Now()
+	summary := testSummary(t, stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: stats.ModeUnary, MaxRetries: 2},
+		stats.Result{Start: start, End: start.Add(10 * time.Millisecond), Successful: true, Status: 200, BytesIn: 10},
+		stats.Result{Start: start, End: start.Add(30 * time.Millisecond), Status: 503, ErrorClass: stats.ErrorHttp5xx, Error: "503 Service Unavailable"},
+	)
+	var out bytes.Buffer
+	if err := WriteJson(&out, summary); err != nil {
+		t.Fatal(err)
+	}
+	var report map[string]any
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want any // nil only checks the field is there
+	}{
+		{"schema_version", float64(SchemaVersion)},
+		{"tool", "lgen"},
+		{"config.protocol", "http"},
+		{"config.max_retries", float64(2)},
+		{"requests.total", float64(2)},
+		{"requests.failed", float64(1)},
+		{"requests.error_rate", 0.5},
+		{"latency_ms.max", float64(30)},
+		{"latency_ms.p99_9", nil},
+		{"uncorrected_latency_ms.p50", nil},
+		{"retries.first_try_latency_ms.max", float64(30)},
+		{"status_codes.503", float64(1)},
+		{"error_classes.http_5xx", float64(1)},
+		{"bytes.received", float64(10)},
+		{"events.reconnects", float64(0)},
+		{"stages", nil},
+		{"interval_seconds", float64(1)},
+		{"timeline", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var value any = report
+			for _, key := range strings.Split(tt.path, ".") {
+				object, ok := value.(map[string]any)
+				if !ok {
+					t.Fatalf("%s is not in the report", tt.path)
+				}
+				if value, ok = object[key]; !ok {
+					t.Fatalf("%s is not in the report", tt.path)
+				}
+			}
+			if tt.want != nil && value != tt.want {
+				t.Errorf("%s = %v, want %v", tt.path, value, tt.want)
+			}
+		})
+	}
+	for _, key := range []string{"phases", "streams", "websocket", "thresholds"} {
+		if _, ok := report[key]; ok {
+			t.Errorf("%s is reported for a run without any", key)
+		}
+	}
+}
+
+func TestWriteJsonReadJson(t *testing.T) {
+	start := time.Now()
+	summary := testSummary(t, stats.RunConfig{Protocol: stats.ProtocolGrpc, Mode: stats.ModeUnary, Method: "Svc/Get"},
+		stats.Result{Start: start, End: start.Add(time.Millisecond), Successful: true})
+	var out bytes.Buffer
+	if err := WriteJson(&out, summary); err != nil {
+		t.Fatal(err)
+	}
+	report, err := ReadJson(&out)
+	if err != nil {
+		t.Fatalf("ReadJson() of a written summary error = %v", err)
+	}
+	if report.SchemaVersion != SchemaVersion || report.Config.Method != "Svc/Get" || report.Requests.Successful != 1 {
+		t.Errorf("read back %+v", report)
+	}
+	if report.StatusCodes["OK"] != 1 {
+		t.Errorf("status_codes = %v, want gRPC status names", report.StatusCodes)
+	}
+}
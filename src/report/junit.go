@@ -0,0 +1,109 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"generator/load/src/stats"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJunit writes the run as a JUnit XML report: a "run" test case holding the text summary,
+// then one test case per threshold, failed when the threshold was violated.
+func WriteJunit(w io.Writer, s *stats.Summary) error {
+	classname := fmt.Sprintf("lgen.%s.%s", s.Config.Protocol, s.Config.Mode)
+	var summary bytes.Buffer
+	s.Print(&summary)
+
+	suite := junitSuite{
+		Name:      fmt.Sprintf("lgen %s %s %s", s.Config.Protocol, s.Config.Mode, s.Config.Target),
+		Time:      s.Duration.Seconds(),
+		Timestamp: s.Started.Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{"protocol", s.Config.Protocol},
+			{"mode", s.Config.Mode},
+			{"target", s.Config.Target},
+			{"method", s.Config.Method},
+			{"requests", fmt.Sprint(s.Requests)},
+			{"throughput_rps", fmt.Sprintf("%.2f", s.Throughput)},
+			{"error_rate", fmt.Sprintf("%.4f", s.ErrorRate())},
+		},
+		Cases: []junitCase{{
+			Name:      "run",
+			Classname: classname,
+			Time:      s.Duration.Seconds(),
+			SystemOut: &junitOutput{summary.String()},
+		}},
+	}
+	for _, r := range s.Thresholds {
+		testCase := junitCase{Name: "threshold " + r.Expression, Classname: classname}
+		if !r.Passed {
+			message := fmt.Sprintf("%s violated: actual %s", r.Expression, r.FormatActual())
+			testCase.Failure = &junitFailure{Message: message, Type: "ThresholdViolated", Text: message}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Tests = len(suite.Cases)
+
+	report := junitSuites{
+		Name:     "lgen",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+func TestWriteJunit(t *testing.T) {
+	start := time.Now()
+	results := []stats.Result{
+		{Start: start, End: start.Add(10 * time.Millisecond), Successful: true, Status: 200},
+		{Start: start, End: start.Add(300 * time.Millisecond), Successful: true, Status: 200},
+		{Start: start, End: start.Add(20 * time.Millisecond), Status: 500, ErrorClass: stats.ErrorHttp5xx},
+		{Start: start, End: start.Add(20 * time.Millisecond), Successful: true, Status: 200},
+	}
+	tests := []struct {
+		name       string
+		thresholds []string
+		failures   []string // messages of the failed test cases, in order
+	}{
+		{"no thresholds", nil, nil},
+		{"all pass", []string{"error_rate<0.5", "p50<1s"}, nil},
+		{"latency violated", []string{"p99<100ms", "error_rate<0.5"}, []string{"p99<100ms violated: actual 300.00ms"}},
+		{"all violated", []string{"error_rate<0.1", "max<=200ms"}, []string{"error_rate<0.1 violated: actual 25.00%", "max<=200ms violated: actual 300.00ms"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := testSummary(t, stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: stats.ModeUnary, Target: "http://x"}, results...)
+			for _, expression := range tt.thresholds {
+				threshold, err := stats.ParseThreshold(expression)
+				if err != nil {
+					t.Fatal(err)
+				}
+				summary.Thresholds = append(summary.Thresholds, threshold.Evaluate(summary))
+			}
+			var out bytes.Buffer
+			if err := WriteJunit(&out, summary); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(out.String(), xml.Header) {
+				t.Errorf("report does not start with the XML header")
+			}
+			var report junitSuites
+			if err := xml.Unmarshal(out.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+
+			wantTests := 1 + len(tt.thresholds)
+			if report.Tests != wantTests || report.Failures != len(tt.failures) || len(report.Suites) != 1 {
+				t.Fatalf("testsuites has %d tests, %d failures, %d suites, want %d, %d, 1",
+					report.Tests, report.Failures, len(report.Suites), wantTests, len(tt.failures))
+			}
+			suite := report.Suites[0]
+			if suite.Tests != wantTests || suite.Failures != len(tt.failures) || len(suite.Cases) != wantTests {
+				t.Fatalf("testsuite has %d tests, %d failures, %d cases, want %d, %d, %d",
+					suite.Tests, suite.Failures, len(suite.Cases), wantTests, len(tt.failures), wantTests)
+			}
+			run := suite.Cases[0]
+			if run.Name != "run" || run.Failure != nil || run.SystemOut == nil || !strings.Contains(run.SystemOut.Text, "Total number of requests: 4") {
+				t.Errorf("run case = %+v, want the passing run with the text summary", run)
+			}
+			var failures []string
+			for i, c := range suite.Cases[1:] {
+				if want := "threshold " + tt.thresholds[i]; c.Name != want || c.Classname != "lgen.http.unary" {
+					t.Errorf("case %d = %s %s, want %s lgen.http.unary", i+1, c.Name, c.Classname, want)
+				}
+				if c.Failure != nil {
+					if c.Failure.Type != "ThresholdViolated" || c.Failure.Text != c.Failure.Message {
+						t.Errorf("failure = %+v", c.Failure)
+					}
+					failures = append(failures, c.Failure.Message)
+				}
+			}
+			if strings.Join(failures, "\n") != strings.Join(tt.failures, "\n") {
+				t.Errorf("failures = %q, want %q", failures, tt.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// DefaultRetryOn lists what is worth retrying by default: failures to reach the server, timeouts,
+// server errors and throttling.
+const DefaultRetryOn = "connect_refused,connection_reset,timeout,http_5xx,429,grpc_unavailable"
+
+// Policy decides whether and when a failed request is sent again, shared by the HTTP and gRPC executors.
+type Policy struct {
+	MaxRetries int             // retries after the first attempt, 0 sends every request once
+	Backoff    time.Duration   // delay before the first retry, doubled for every further retry
+	MaxBackoff time.Duration   // cap of the delay between two attempts, 0 for none
+	Jitter     float64         // fraction of each delay that is randomized, from 0 to 1
+	TryTimeout time.Duration   // limit of a single attempt, 0 for none
+	RetryOn    map[string]bool // error classes and status codes worth retrying
+}
+
+// ParseRetryOn parses a comma separated list of error classes (e.g. http_5xx, timeout) and status codes (e.g. 429).
+func ParseRetryOn(list string) (map[string]bool, error) {
+	retryOn := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if strings.Trim(item, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return nil, fmt.Errorf("invalid retry condition %q, expected an error class such as http_5xx or a status code", item)
+		}
+		retryOn[item] = true
+	}
+	return retryOn, nil
+}
+
+// Retryable reports whether a failed attempt may be retried, a failed assertion never is since the
+// same response would fail it again.
+func (p Policy) Retryable(r stats.Result) bool {
+	if r.Successful || r.ErrorClass == stats.ErrorAssertion {
+		return false
+	}
+	return p.RetryOn[r.ErrorClass] || (r.Status != 0 && p.RetryOn[strconv.Itoa(r.Status)])
+}
+
+// Delay is the pause before the given retry, 1 for the first one: exponential backoff capped at
+// MaxBackoff when set, with the Jitter fraction of it randomized.
+func (p Policy) Delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && delay <= math.MaxInt64/2; i++ {
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+// Run makes attempts until one succeeds, fails in a way the policy doesn't retry or the retries run
+// out, pausing between attempts. Every attempt starts from a cleared outcome in result and gets a
+// context limited to TryTimeout, canceled once the attempt returns. Canceling ctx cuts the pause
+// short and leaves the outcome of the last attempt in result.
+func (p Policy) Run(ctx context.Context, result *stats.Result, attempt func(ctx context.Context)) {
+	for n := 1; ; n++ {
+		result.Attempts = n
+		result.Successful, result.Status, result.ErrorClass, result.Error, result.Body, result.Assertion = false, 0, "", "", "", ""
+		result.Phases, result.Proto = nil, ""
+		tryCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.TryTimeout > 0 {
+			tryCtx, cancel = context.WithTimeout(ctx, p.TryTimeout)
+		}
+		attempt(tryCtx)
+		cancel()
+		if n == 1 {
+			result.FirstTryEnd = time.Now()
+		}
+		if n > p.MaxRetries || !p.Retryable(*result) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return // canceled while backing off, the last attempt is the outcome
+		case <-time.After(p.Delay(n)):
+		}
+	}
+}
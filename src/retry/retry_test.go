@@ -0,0 +1,175 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+func TestParseRetryOn(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{DefaultRetryOn, []string{"connect_refused", "connection_reset", "timeout", "http_5xx", "429", "grpc_unavailable"}, false},
+		{" HTTP_5xx , 503,,", []string{"http_5xx", "503"}, false},
+		{"", nil, false},
+		{"http-5xx", nil, true},
+		{"5xx;429", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := ParseRetryOn(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetryOn(%q) error = %v, want error %v", tt.list, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("ParseRetryOn(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+			for _, item := range tt.want {
+				if !got[item] {
+					t.Errorf("ParseRetryOn(%q) misses %q", tt.list, item)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	retryOn, _ := ParseRetryOn("http_5xx,429,timeout")
+	p := Policy{RetryOn: retryOn}
+	tests := []struct {
+		name   string
+		result stats.Result
+		want   bool
+	}{
+		{"success", stats.Result{Successful: true, Status: 200}, false},
+		{"listed class", stats.Result{Status: 503, ErrorClass: stats.ErrorHttp5xx}, true},
+		{"listed status", stats.Result{Status: 429, ErrorClass: stats.ErrorHttp4xx}, true},
+		{"other status", stats.Result{Status: 404, ErrorClass: stats.ErrorHttp4xx}, false},
+		{"timeout", stats.Result{ErrorClass: stats.ErrorTimeout}, true},
+		{"refused", stats.Result{ErrorClass: stats.ErrorConnectRefused}, false},
+		{"failed assertion", stats.Result{Status: 503, ErrorClass: stats.ErrorAssertion}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Retryable(tt.result); got != tt.want {
+				t.Errorf("Retryable(%+v) = %v, want %v", tt.result, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelay(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name       string
+		backoff    time.Duration
+		maxBackoff time.Duration
+		retry      int
+		want       time.Duration
+	}{
+		{"first retry", 100 * ms, 2 * time.Second, 1, 100 * ms},
+		{"second retry doubles", 100 * ms, 2 * time.Second, 2, 200 * ms},
+		{"fifth retry", 100 * ms, 2 * time.Second, 5, 1600 * ms},
+		{"capped", 100 * ms, 2 * time.Second, 6, 2 * time.Second},
+		{"long after the cap", 100 * ms, 2 * time.Second, 1000, 2 * time.Second},
+		{"cap below the backoff", 100 * ms, 50 * ms, 1, 50 * ms},
+		{"no cap", 100 * ms, 0, 8, 12800 * ms},
+		{"no cap long run stops short of overflowing", time.Second, 0, 1000, time.Second << 33},
+		{"no backoff", 0, 0, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{Backoff: tt.backoff, MaxBackoff: tt.maxBackoff}
+			if got := p.Delay(tt.retry); got != tt.want {
+				t.Errorf("Delay(%d) = %v, want %v", tt.retry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelayJitter(t *testing.T) {
+	p := Policy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := p.Delay(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Delay(2) with half jitter = %v, want between 100ms and 200ms", got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	retryOn, _ := ParseRetryOn("http_5xx")
+	tests := []struct {
+		name       string
+		maxRetries int
+		outcomes   []int // status of every attempt, 200 succeeds
+		attempts   int
+		successful bool
+	}{
+		{"first try succeeds", 3, []int{200}, 1, true},
+		{"succeeds on retry", 3, []int{503, 503, 200}, 3, true},
+		{"retries run out", 2, []int{503, 503, 503, 200}, 3, false},
+		{"no retries", 0, []int{503, 200}, 1, false},
+		{"not retryable", 3, []int{404, 200}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{MaxRetries: tt.maxRetries, Backoff: time.Microsecond, RetryOn: retryOn, TryTimeout: time.Second}
+			result := stats.Result{Start: time.Now()}
+			calls := 0
+			p.Run(context.Background(), &result, func(ctx context.Context) {
+				if _, ok := ctx.Deadline(); !ok {
+					t.Error("attempt context has no deadline despite TryTimeout")
+				}
+				if result.Status != 0 || result.ErrorClass != "" {
+					t.Errorf("attempt %d starts from %+v, want a cleared outcome", calls+1, result)
+				}
+				status := tt.outcomes[calls]
+				calls++
+				result.Status = status
+				result.Successful = status == 200
+				result.ErrorClass = stats.ClassifyHttpStatus(status)
+			})
+			if calls != tt.attempts || result.Attempts != tt.attempts {
+				t.Errorf("made %d attempts, recorded %d, want %d", calls, result.Attempts, tt.attempts)
+			}
+			if result.Successful != tt.successful {
+				t.Errorf("Successful = %v, want %v", result.Successful, tt.successful)
+			}
+			if result.FirstTryEnd.IsZero() {
+				t.Error("FirstTryEnd not set")
+			}
+		})
+	}
+}
+
+func TestRunCanceledDuringBackoff(t *testing.T) {
+	retryOn, _ := ParseRetryOn("http_5xx")
+	p := Policy{MaxRetries: 5, Backoff: time.Hour, RetryOn: retryOn}
+	ctx, cancel := context.WithCancel(context.Background())
+	result := stats.Result{Start: time.Now()}
+	calls := 0
+	started := time.Now()
+	p.Run(ctx, &result, func(ctx context.Context) {
+		calls++
+		result.Status = 503
+		result.ErrorClass = stats.ErrorHttp5xx
+		cancel() // the run ends, e.g. the SSE hold elapsed, while the retry waits
+	})
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Run returned after %v, want it to stop backing off once canceled", elapsed)
+	}
+	if calls != 1 || result.Attempts != 1 {
+		t.Errorf("made %d attempts, recorded %d, want 1", calls, result.Attempts)
+	}
+	if result.Status != 503 || result.ErrorClass != stats.ErrorHttp5xx {
+		t.Errorf("result = %d %s, want the outcome of the last attempt", result.Status, result.ErrorClass)
+	}
+}
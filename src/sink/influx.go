@@ -0,0 +1,172 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// Influx writes the results of a run aggregated per interval as InfluxDB line protocol points,
+// appended to a file or posted to an HTTP write endpoint such as
+// http://localhost:8086/api/v2/write?org=acme&bucket=bench&precision=ns.
+type Influx struct {
+	collector *stats.Collector
+	interval  time.Duration
+	tags      string
+	url       string // set when writing over HTTP
+	token     string
+	file      *os.File // set when writing to a file
+	client    *http.Client
+	err       error // first write error, only touched by the flush loop until Close
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	failed    int
+	events    int
+	bytesIn   int64
+	bytesOut  int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewInflux starts writing a point every interval to target, an http(s) URL or a file path.
+// token is sent as "Authorization: Token <token>" to HTTP endpoints when set.
+func NewInflux(collector *stats.Collector, target string, token string, interval time.Duration) (*Influx, error) {
+	config := collector.Config()
+	i := &Influx{
+		collector: collector,
+		interval:  interval,
+		tags: fmt.Sprintf("run_id=%s,protocol=%s,mode=%s,target=%s,method=%s",
+			escapeTag(config.RunId), escapeTag(config.Protocol), escapeTag(config.Mode),
+			escapeTag(config.Target), escapeTag(config.Method)),
+		token: token,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		i.url = target
+		i.client = &http.Client{Timeout: 10 * time.Second}
+	} else {
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		i.file = file
+	}
+	go i.loop()
+	return i, nil
+}
+
+func (i *Influx) Record(r stats.Result) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.latencies = append(i.latencies, r.Latency())
+	if !r.Successful {
+		i.failed++
+	}
+	i.events += r.Events
+	i.bytesIn += r.BytesIn
+	i.bytesOut += r.BytesOut
+}
+
+// Close writes the point of the last, partial interval and reports the first write error.
+func (i *Influx) Close() error {
+	close(i.stop)
+	<-i.done
+	if i.file != nil {
+		if err := i.file.Close(); err != nil && i.err == nil {
+			i.err = err
+		}
+	}
+	return i.err
+}
+
+func (i *Influx) loop() {
+	defer close(i.done)
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	started := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			i.flush(now, i.interval)
+			started = now
+		case <-i.stop:
+			now := time.Now()
+			i.flush(now, now.Sub(started))
+			return
+		}
+	}
+}
+
+// flush writes the point of the results recorded since the previous flush, window long.
+func (i *Influx) flush(now time.Time, window time.Duration) {
+	i.mu.Lock()
+	latencies := i.latencies
+	failed, events, bytesIn, bytesOut := i.failed, i.events, i.bytesIn, i.bytesOut
+	i.latencies = nil
+	i.failed, i.events, i.bytesIn, i.bytesOut = 0, 0, 0, 0
+	i.mu.Unlock()
+
+	requests := len(latencies)
+	if requests == 0 && window < i.interval {
+		return // nothing finished in the tail of the run
+	}
+	fields := fmt.Sprintf("requests=%di,errors=%di,in_flight=%di,events=%di,bytes_sent=%di,bytes_received=%di",
+		requests, failed, i.collector.InFlight(), events, bytesOut, bytesIn)
+	if window > 0 {
+		fields += fmt.Sprintf(",rps=%g", float64(requests)/window.Seconds())
+	}
+	if requests > 0 {
+		d := stats.NewDistribution(latencies)
+		fields += fmt.Sprintf(",latency_mean_ms=%g,latency_p50_ms=%g,latency_p90_ms=%g,latency_p99_ms=%g,latency_max_ms=%g",
+			milliseconds(d.Mean), milliseconds(d.P50), milliseconds(d.P90), milliseconds(d.P99), milliseconds(d.Max))
+	}
+	line := fmt.Sprintf("lgen,%s %s %d\n", i.tags, fields, now.UnixNano())
+	if err := i.write(line); err != nil && i.err == nil {
+		i.err = err
+	}
+}
+
+func (i *Influx) write(line string) error {
+	if i.file != nil {
+		_, err := io.WriteString(i.file, line)
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, i.url, bytes.NewBufferString(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.token != "" {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("influx write to %s: %s %s", i.url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+var tagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+
+// escapeTag escapes a tag value for the line protocol, an empty value is not allowed so it becomes "none".
+func escapeTag(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return tagEscaper.Replace(value)
+}
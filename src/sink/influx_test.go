@@ -0,0 +1,154 @@
+package sink
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// influxConfig has tag values that need every kind of line protocol escaping.
+var influxConfig = stats.RunConfig{
+	Protocol: stats.ProtocolHttp,
+	Mode:     stats.ModeUnary,
+	Target:   "http://x/a b,c=d",
+	Method:   "POST",
+}
+
+// recordInflux records a success and a failure, enough for every field of a point.
+func recordInflux(i *Influx) {
+	start := time.Now()
+	i.Record(stats.Result{Start: start, End: start.Add(10 * time.Millisecond), Successful: true, Status: 200, BytesOut: 5, BytesIn: 50})
+	i.Record(stats.Result{Start: start, End: start.Add(30 * time.Millisecond), Status: 503, BytesOut: 5, Events: 2})
+}
+
+// checkPoint checks that line is the point of recordInflux.
+func checkPoint(t *testing.T, line string) {
+	t.Helper()
+	wantPrefix := `lgen,run_id=none,protocol=http,mode=unary,target=http://x/a\ b\,c\=d,method=POST ` +
+		"requests=2i,errors=1i,in_flight=0i,events=2i,bytes_sent=10i,bytes_received=50i,rps="
+	if !strings.HasPrefix(line, wantPrefix) {
+		t.Errorf("point = %q, want it to start with %q", line, wantPrefix)
+	}
+	for _, field := range []string{",latency_mean_ms=20,", ",latency_max_ms=30 "} {
+		if !strings.Contains(line, field) {
+			t.Errorf("point = %q, want field %q", line, field)
+		}
+	}
+	if !strings.HasSuffix(line, "\n") {
+		t.Errorf("point = %q, want it to end the line", line)
+	}
+}
+
+func TestInfluxFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.lp")
+	// The interval never elapses, so the only point is the partial one written on Close.
+	i, err := NewInflux(stats.NewCollector(influxConfig), path, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordInflux(i)
+	if err := i.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	points, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(points), "\n"); n != 1 {
+		t.Fatalf("wrote %d points, want 1:\n%s", n, points)
+	}
+	checkPoint(t, string(points))
+}
+
+func TestInfluxFileEmptyTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.lp")
+	i, err := NewInflux(stats.NewCollector(influxConfig), path, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if points, _ := os.ReadFile(path); len(points) != 0 {
+		t.Errorf("a run without results wrote %q, want nothing", points)
+	}
+}
+
+func TestInfluxHttp(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		status  int
+		wantErr bool
+	}{
+		{"no token", "", http.StatusNoContent, false},
+		{"token", "s3cret", http.StatusNoContent, false},
+		{"rejected", "wrong", http.StatusUnauthorized, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var auth, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				auth, body = r.Header.Get("Authorization"), string(b)
+				mu.Unlock()
+				w.WriteHeader(tt.status)
+				if tt.status >= 300 {
+					io.WriteString(w, `{"code":"unauthorized"}`)
+				}
+			}))
+			defer server.Close()
+
+			i, err := NewInflux(stats.NewCollector(influxConfig), server.URL+"/api/v2/write?bucket=b", tt.token, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recordInflux(i)
+			err = i.Close()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Close() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "401") {
+				t.Errorf("Close() error = %v, want it to name the status", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			wantAuth := ""
+			if tt.token != "" {
+				wantAuth = "Token " + tt.token
+			}
+			if auth != wantAuth {
+				t.Errorf("Authorization = %q, want %q", auth, wantAuth)
+			}
+			checkPoint(t, body)
+		})
+	}
+}
+
+func TestEscapeTag(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"plain", "plain"},
+		{"", "none"},
+		{"a b", `a\ b`},
+		{"a,b=c", `a\,b\=c`},
+		{"a\nb", `a\nb`},
+	}
+	for _, tt := range tests {
+		if got := escapeTag(tt.value); got != tt.want {
+			t.Errorf("escapeTag(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"generator/load/src/logging"
+	"generator/load/src/stats"
+)
+
+const (
+	ProgressAuto = "auto" // dashboard on a terminal, one line per update otherwise
+	ProgressTui  = "tui"
+	ProgressLine = "line"
+)
+
+func ValidProgressMode(mode string) bool {
+	return mode == ProgressAuto || mode == ProgressTui || mode == ProgressLine
+}
+
+// progressWindow is how far back the rolling latency percentiles and error rate look.
+const progressWindow = 5 * time.Second
+
+type progressSample struct {
+	end     time.Time
+	latency time.Duration
+	failed  bool
+	events  int
+}
+
+// Progress renders the state of a running load once a second, as a redrawn dashboard
+// on a terminal or as plain lines when the output is redirected. While the dashboard is
+// drawn, log records are held back and written above it on the next redraw.
+type Progress struct {
+	collector *stats.Collector
+	out       *os.File
+	tui       bool
+	started   time.Time
+
+	mu        sync.Mutex
+	recent    []progressSample // results that finished within progressWindow
+	completed int
+	failed    int
+	drawn     int // lines of the last dashboard frame, erased before drawing the next one
+
+	logs       heldLogs // log records written while the dashboard is drawn
+	restoreLog func()
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewProgress(collector *stats.Collector, mode string, out *os.File) *Progress {
+	p := &Progress{
+		collector: collector,
+		out:       out,
+		tui:       mode == ProgressTui || (mode == ProgressAuto && isTerminal(out)),
+		started:   time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if p.tui {
+		p.restoreLog = logging.Redirect(&p.logs)
+	}
+	go p.loop()
+	return p
+}
+
+func (p *Progress) Record(r stats.Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed++
+	if !r.Successful {
+		p.failed++
+	}
+	p.recent = append(p.recent, progressSample{
+		end:     r.End,
+		latency: r.Latency(),
+		failed:  !r.Successful,
+		events:  r.Events,
+	})
+}
+
+func (p *Progress) Close() error {
+	close(p.stop)
+	<-p.done
+	if p.tui {
+		p.restoreLog()
+		p.out.Write(p.logs.take()) // records written since the last frame
+	}
+	return nil
+}
+
+func (p *Progress) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.render(time.Now())
+		case <-p.stop:
+			p.render(time.Now())
+			return
+		}
+	}
+}
+
+func (p *Progress) render(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Drop samples older than the window, they are ordered by collection, close enough to end time.
+	cut := 0
+	for cut < len(p.recent) && now.Sub(p.recent[cut].end) > progressWindow {
+		cut++
+	}
+	p.recent = p.recent[cut:]
+
+	var lastSecond int = 0
+	var lastSecondEvents int = 0
+	var windowFailed int = 0
+	latencies := make([]time.Duration, 0, len(p.recent))
+	for _, s := range p.recent {
+		latencies = append(latencies, s.latency)
+		if s.failed {
+			windowFailed++
+		}
+		if now.Sub(s.end) <= time.Second {
+			lastSecond++
+			lastSecondEvents += s.events
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var windowErrorRate float64 = 0
+	if len(p.recent) > 0 {
+		windowErrorRate = float64(windowFailed) / float64(len(p.recent)) * 100
+	}
+
+	config := p.collector.Config()
+	elapsed := now.Sub(p.started).Truncate(time.Second)
+	p50 := stats.Percentile(latencies, 50)
+	p99 := stats.Percentile(latencies, 99)
+	streaming := config.Streaming()
+
+	if !p.tui {
+		line := fmt.Sprintf("[%6s] %d/%d done, %d in flight, %d req/s, p50 %s p99 %s, errors %d (%.1f%%)",
+			elapsed, p.completed, config.Requests, p.collector.InFlight(), lastSecond,
+			formatLatency(p50), formatLatency(p99), p.failed, windowErrorRate)
+		if streaming {
+			line += fmt.Sprintf(", %d events/s", lastSecondEvents)
+		}
+		fmt.Fprintln(p.out, line)
+		return
+	}
+
+	var done float64 = 0
+	if config.Requests > 0 {
+		done = float64(p.completed) / float64(config.Requests) * 100
+	}
+	lines := []string{
+		fmt.Sprintf("lgen %s %s %s", config.Protocol, config.Mode, config.Target),
+		fmt.Sprintf("  elapsed      %s", elapsed),
+		fmt.Sprintf("  requests     %d/%d done (%.1f%%), %d in flight", p.completed, config.Requests, done, p.collector.InFlight()),
+		fmt.Sprintf("  throughput   %d req/s", lastSecond),
+		fmt.Sprintf("  latency      p50 %s  p99 %s  (last %s)", formatLatency(p50), formatLatency(p99), progressWindow),
+		fmt.Sprintf("  errors       %d total, %.1f%% in the last %s", p.failed, windowErrorRate, progressWindow),
+	}
+	if streaming {
+		lines = append(lines, fmt.Sprintf("  events       %d events/s", lastSecondEvents))
+	}
+	if p.drawn > 0 {
+		fmt.Fprintf(p.out, "\033[%dA\033[J", p.drawn)
+	}
+	p.out.Write(p.logs.take())
+	fmt.Fprintln(p.out, strings.Join(lines, "\n"))
+	p.drawn = len(lines)
+}
+
+// heldLogs buffers the log records written while the dashboard is drawn.
+type heldLogs struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (h *heldLogs) Write(b []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.buf.Write(b)
+}
+
+// take returns the records held so far and empties the buffer.
+func (h *heldLogs) take() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	held := bytes.Clone(h.buf.Bytes())
+	h.buf.Reset()
+	return held
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// progressOutput creates the file a Progress writes to, read back with readProgress.
+func progressOutput(t *testing.T) *os.File {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "progress.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { out.Close() })
+	return out
+}
+
+func readProgress(t *testing.T, out *os.File) string {
+	t.Helper()
+	b, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestProgressLine(t *testing.T) {
+	now := time.Now()
+	// finished builds a result that ended ago before now and took latency.
+	finished := func(ago time.Duration, latency time.Duration, successful bool, events int) stats.Result {
+		end := now.Add(-ago)
+		return stats.Result{Start: end.Add(-latency), End: end, Successful: successful, Events: events}
+	}
+	tests := []struct {
+		name    string
+		mode    string
+		results []stats.Result
+		want    string
+	}{
+		{
+			"nothing done yet", stats.ModeUnary, nil,
+			"[    3s] 0/10 done, 0 in flight, 0 req/s, p50 0.0ms p99 0.0ms, errors 0 (0.0%)\n",
+		},
+		{
+			"unary",
+			stats.ModeUnary,
+			[]stats.Result{
+				finished(500*time.Millisecond, 10*time.Millisecond, true, 0),
+				finished(200*time.Millisecond, 20*time.Millisecond, true, 0),
+				finished(2*time.Second, 30*time.Millisecond, false, 0),
+				finished(100*time.Millisecond, 40*time.Millisecond, true, 0),
+			},
+			"[    3s] 4/10 done, 0 in flight, 3 req/s, p50 20.0ms p99 40.0ms, errors 1 (25.0%)\n",
+		},
+		{
+			"failures out of the window still count in total",
+			stats.ModeUnary,
+			[]stats.Result{
+				finished(time.Minute, 500*time.Millisecond, false, 0),
+				finished(100*time.Millisecond, 10*time.Millisecond, true, 0),
+			},
+			"[    3s] 2/10 done, 0 in flight, 1 req/s, p50 10.0ms p99 10.0ms, errors 1 (0.0%)\n",
+		},
+		{
+			"streams add events",
+			stats.ModeSse,
+			[]stats.Result{
+				finished(100*time.Millisecond, time.Second, true, 7),
+				finished(3*time.Second, time.Second, true, 50),
+			},
+			"[    3s] 2/10 done, 0 in flight, 1 req/s, p50 1000.0ms p99 1000.0ms, errors 0 (0.0%), 7 events/s\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := progressOutput(t)
+			p := &Progress{
+				collector: stats.NewCollector(stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: tt.mode, Requests: 10}),
+				out:       out,
+				started:   now.Add(-3500 * time.Millisecond),
+			}
+			for _, r := range tt.results {
+				p.Record(r)
+			}
+			p.render(now)
+			if got := readProgress(t, out); got != tt.want {
+				t.Errorf("line = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressAutoLineMode(t *testing.T) {
+	out := progressOutput(t)
+	p := NewProgress(stats.NewCollector(stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: stats.ModeUnary, Requests: 1}), ProgressAuto, out)
+	if p.tui {
+		t.Fatal("auto mode draws the dashboard into a file")
+	}
+	start := time.Now()
+	p.Record(stats.Result{Start: start, End: start.Add(time.Millisecond), Successful: true})
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := readProgress(t, out)
+	if strings.Contains(got, "\033[") {
+		t.Errorf("line mode output %q has terminal escapes", got)
+	}
+	if lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n"); !strings.Contains(lines[len(lines)-1], "1/1 done") {
+		t.Errorf("last line = %q, want the final state", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request duration histogram.
+var latencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Prometheus exposes the client side metrics of a running load in the Prometheus text format on /metrics.
+type Prometheus struct {
+	collector *stats.Collector
+	server    *http.Server
+	addr      net.Addr // where metrics are served, resolved when addr asked for port 0
+	served    chan error
+
+	mu            sync.Mutex
+	requests      map[string]int // by status and result label pairs
+	bucketCounts  []int          // cumulative histogram counts, one per latency bucket
+	latencyCount  int
+	latencySum    float64
+	events        int
+	bytesSent     int64
+	bytesReceived int64
+}
+
+// NewPrometheus starts serving metrics on addr, e.g. ":9100".
+func NewPrometheus(collector *stats.Collector, addr string) (*Prometheus, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Prometheus{
+		collector:    collector,
+		addr:         listener.Addr(),
+		served:       make(chan error, 1),
+		requests:     make(map[string]int),
+		bucketCounts: make([]int, len(latencyBuckets)),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", p.serveMetrics)
+	p.server = &http.Server{Handler: mux}
+	go func() {
+		p.served <- p.server.Serve(listener)
+	}()
+	return p, nil
+}
+
+func (p *Prometheus) Record(r stats.Result) {
+	config := p.collector.Config()
+	result := "success"
+	if !r.Successful {
+		result = "failure"
+	}
+	labels := fmt.Sprintf(`status="%s",result="%s"`, escapeLabel(stats.StatusName(config.Protocol, r.Status)), result)
+	seconds := r.Latency().Seconds()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests[labels]++
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			p.bucketCounts[i]++
+		}
+	}
+	p.latencyCount++
+	p.latencySum += seconds
+	p.events += r.Events
+	p.bytesSent += r.BytesOut
+	p.bytesReceived += r.BytesIn
+}
+
+func (p *Prometheus) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.server.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := <-p.served; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (p *Prometheus) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.write(w)
+}
+
+func (p *Prometheus) write(w io.Writer) {
+	config := p.collector.Config()
+	common := fmt.Sprintf(`protocol="%s",mode="%s",method="%s"`,
+		escapeLabel(config.Protocol), escapeLabel(config.Mode), escapeLabel(config.Method))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP lgen_requests_total Completed requests by status and result.")
+	fmt.Fprintln(w, "# TYPE lgen_requests_total counter")
+	keys := make([]string, 0, len(p.requests))
+	for labels := range p.requests {
+		keys = append(keys, labels)
+	}
+	sort.Strings(keys)
+	for _, labels := range keys {
+		fmt.Fprintf(w, "lgen_requests_total{%s,%s} %d\n", common, labels, p.requests[labels])
+	}
+
+	fmt.Fprintln(w, "# HELP lgen_request_duration_seconds Request latency, measured from the intended send time.")
+	fmt.Fprintln(w, "# TYPE lgen_request_duration_seconds histogram")
+	for i, bound := range latencyBuckets {
+		fmt.Fprintf(w, "lgen_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", common, bound, p.bucketCounts[i])
+	}
+	fmt.Fprintf(w, "lgen_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", common, p.latencyCount)
+	fmt.Fprintf(w, "lgen_request_duration_seconds_sum{%s} %g\n", common, p.latencySum)
+	fmt.Fprintf(w, "lgen_request_duration_seconds_count{%s} %d\n", common, p.latencyCount)
+
+	fmt.Fprintln(w, "# HELP lgen_requests_in_flight Requests sent and not completed yet.")
+	fmt.Fprintln(w, "# TYPE lgen_requests_in_flight gauge")
+	fmt.Fprintf(w, "lgen_requests_in_flight{%s} %d\n", common, p.collector.InFlight())
+
+	fmt.Fprintln(w, "# HELP lgen_stream_events_total SSE events and server streamed messages received.")
+	fmt.Fprintln(w, "# TYPE lgen_stream_events_total counter")
+	fmt.Fprintf(w, "lgen_stream_events_total{%s} %d\n", common, p.events)
+
+	fmt.Fprintln(w, "# HELP lgen_bytes_sent_total Request payload bytes sent.")
+	fmt.Fprintln(w, "# TYPE lgen_bytes_sent_total counter")
+	fmt.Fprintf(w, "lgen_bytes_sent_total{%s} %d\n", common, p.bytesSent)
+
+	fmt.Fprintln(w, "# HELP lgen_bytes_received_total Response payload bytes received.")
+	fmt.Fprintln(w, "# TYPE lgen_bytes_received_total counter")
+	fmt.Fprintf(w, "lgen_bytes_received_total{%s} %d\n", common, p.bytesReceived)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
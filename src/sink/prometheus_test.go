@@ -0,0 +1,94 @@
+package sink
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	collector := stats.NewCollector(stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: stats.ModeUnary, Method: `say "hi"\now`})
+	p, err := NewPrometheus(collector, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, r := range []stats.Result{
+		{Start: start, End: start.Add(3 * time.Millisecond), Successful: true, Status: 200, BytesOut: 10, BytesIn: 100},
+		{Start: start, End: start.Add(40 * time.Millisecond), Successful: true, Status: 200, BytesOut: 10, BytesIn: 100},
+		{Start: start, End: start.Add(2 * time.Second), Status: 503, BytesOut: 10},
+		{Start: start, End: start.Add(time.Minute), ErrorClass: stats.ErrorTimeout},
+	} {
+		p.Record(r)
+	}
+
+	resp, err := http.Get("http://" + p.addr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", got)
+	}
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(body), "\n") {
+		lines[line] = true
+	}
+
+	common := `protocol="http",mode="unary",method="say \"hi\"\\now"`
+	tests := []string{
+		"# TYPE lgen_requests_total counter",
+		`lgen_requests_total{` + common + `,status="200",result="success"} 2`,
+		`lgen_requests_total{` + common + `,status="503",result="failure"} 1`,
+		`lgen_requests_total{` + common + `,status="no response",result="failure"} 1`,
+		"# TYPE lgen_request_duration_seconds histogram",
+		`lgen_request_duration_seconds_bucket{` + common + `,le="0.001"} 0`,
+		`lgen_request_duration_seconds_bucket{` + common + `,le="0.005"} 1`,
+		`lgen_request_duration_seconds_bucket{` + common + `,le="0.05"} 2`,
+		`lgen_request_duration_seconds_bucket{` + common + `,le="2.5"} 3`,
+		`lgen_request_duration_seconds_bucket{` + common + `,le="30"} 3`,
+		`lgen_request_duration_seconds_bucket{` + common + `,le="+Inf"} 4`,
+		`lgen_request_duration_seconds_count{` + common + `} 4`,
+		`lgen_bytes_sent_total{` + common + `} 30`,
+		`lgen_bytes_received_total{` + common + `} 200`,
+	}
+	for _, want := range tests {
+		if !lines[want] {
+			t.Errorf("metrics miss the line %s\n%s", want, body)
+		}
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if conn, err := net.Dial("tcp", p.addr.String()); err == nil {
+		conn.Close()
+		t.Error("metrics are still served after Close")
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"plain", "plain"},
+		{`a"b`, `a\"b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+		{`\"` + "\n", `\\\"\n`},
+	}
+	for _, tt := range tests {
+		if got := escapeLabel(tt.value); got != tt.want {
+			t.Errorf("escapeLabel(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
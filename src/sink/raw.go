@@ -0,0 +1,126 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+var rawHeader = []string{
+	"start", "intended", "latency_ms", "uncorrected_latency_ms", "successful", "status",
+	"bytes_out", "bytes_in", "events", "worker", "request", "error_class", "error",
+}
+
+type rawRecord struct {
+	Start                time.Time `json:"start"`
+	Intended             time.Time `json:"intended"`
+	LatencyMs            float64   `json:"latency_ms"`
+	UncorrectedLatencyMs float64   `json:"uncorrected_latency_ms"`
+	Successful           bool      `json:"successful"`
+	Status               int       `json:"status"`
+	BytesOut             int64     `json:"bytes_out"`
+	BytesIn              int64     `json:"bytes_in"`
+	Events               int       `json:"events"`
+	Worker               int       `json:"worker"`
+	Request              int       `json:"request"`
+	ErrorClass           string    `json:"error_class,omitempty"`
+	Error                string    `json:"error,omitempty"`
+}
+
+// RawWriter streams every result of a run to a CSV or JSON lines file, picked by the file extension.
+type RawWriter struct {
+	file    *os.File
+	buf     *bufio.Writer
+	csv     *csv.Writer   // set for .csv files
+	encoder *json.Encoder // set for .jsonl files
+	err     error
+}
+
+func NewRawWriter(path string) (*RawWriter, error) {
+	ext := filepath.Ext(path)
+	if ext != ".csv" && ext != ".jsonl" {
+		return nil, fmt.Errorf("raw output %q must end with .csv or .jsonl", path)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &RawWriter{
+		file: file,
+		buf:  bufio.NewWriterSize(file, 64*1024),
+	}
+	if ext == ".csv" {
+		w.csv = csv.NewWriter(w.buf)
+		w.err = w.csv.Write(rawHeader)
+	} else {
+		w.encoder = json.NewEncoder(w.buf)
+	}
+	return w, nil
+}
+
+func (w *RawWriter) Record(r stats.Result) {
+	if w.err != nil {
+		return
+	}
+	record := rawRecord{
+		Start:                r.Start,
+		Intended:             r.Intended,
+		LatencyMs:            milliseconds(r.Latency()),
+		UncorrectedLatencyMs: milliseconds(r.UncorrectedLatency()),
+		Successful:           r.Successful,
+		Status:               r.Status,
+		BytesOut:             r.BytesOut,
+		BytesIn:              r.BytesIn,
+		Events:               r.Events,
+		Worker:               r.Worker,
+		Request:              r.Request,
+		ErrorClass:           r.ErrorClass,
+		Error:                r.Error,
+	}
+	if w.encoder != nil {
+		w.err = w.encoder.Encode(record)
+		return
+	}
+	w.err = w.csv.Write([]string{
+		record.Start.Format(time.RFC3339Nano),
+		record.Intended.Format(time.RFC3339Nano),
+		strconv.FormatFloat(record.LatencyMs, 'f', 3, 64),
+		strconv.FormatFloat(record.UncorrectedLatencyMs, 'f', 3, 64),
+		strconv.FormatBool(record.Successful),
+		strconv.Itoa(record.Status),
+		strconv.FormatInt(record.BytesOut, 10),
+		strconv.FormatInt(record.BytesIn, 10),
+		strconv.Itoa(record.Events),
+		strconv.Itoa(record.Worker),
+		strconv.Itoa(record.Request),
+		record.ErrorClass,
+		record.Error,
+	})
+}
+
+func (w *RawWriter) Close() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if w.err == nil {
+			w.err = w.csv.Error()
+		}
+	}
+	if err := w.buf.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if err := w.file.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
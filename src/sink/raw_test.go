@@ -0,0 +1,110 @@
+package sink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+)
+
+// rawResults are a success and a failure whose error needs CSV quoting.
+func rawResults() []stats.Result {
+	intended := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	start := intended.Add(2 * time.Millisecond)
+	return []stats.Result{
+		{Worker: 1, Request: 7, Intended: intended, Start: start, End: start.Add(10 * time.Millisecond), Successful: true, Status: 200, BytesOut: 5, BytesIn: 50},
+		{Worker: 0, Request: 8, Intended: intended, Start: start, End: start.Add(1500 * time.Microsecond), Status: 503, ErrorClass: stats.ErrorHttp5xx, Error: `bad "gateway", retry`},
+	}
+}
+
+// writeRaw records rawResults to a new file named name and returns its contents.
+func writeRaw(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	w, err := NewRawWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rawResults() {
+		w.Record(r)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRawWriterCsv(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(writeRaw(t, "raw.csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		rawHeader,
+		{"2026-01-02T03:04:05.002Z", "2026-01-02T03:04:05Z", "12.000", "10.000", "true", "200", "5", "50", "0", "1", "7", "", ""},
+		{"2026-01-02T03:04:05.002Z", "2026-01-02T03:04:05Z", "3.500", "1.500", "false", "503", "0", "0", "0", "0", "8", "http_5xx", `bad "gateway", retry`},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q\nwant %q", rows, want)
+	}
+}
+
+func TestRawWriterJsonl(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(writeRaw(t, "raw.jsonl"), "\n"), "\n")
+	want := []map[string]any{
+		{
+			"start": "2026-01-02T03:04:05.002Z", "intended": "2026-01-02T03:04:05Z", "latency_ms": 12.0, "uncorrected_latency_ms": 10.0,
+			"successful": true, "status": 200.0, "bytes_out": 5.0, "bytes_in": 50.0, "events": 0.0, "worker": 1.0, "request": 7.0,
+		},
+		{
+			"start": "2026-01-02T03:04:05.002Z", "intended": "2026-01-02T03:04:05Z", "latency_ms": 3.5, "uncorrected_latency_ms": 1.5,
+			"successful": false, "status": 503.0, "bytes_out": 0.0, "bytes_in": 0.0, "events": 0.0, "worker": 0.0, "request": 8.0,
+			"error_class": "http_5xx", "error": `bad "gateway", retry`,
+		},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("wrote %d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("line %d = %v\nwant %v", i+1, got, want[i])
+		}
+	}
+}
+
+func TestNewRawWriterExtension(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"raw.csv", false},
+		{"raw.jsonl", false},
+		{"raw.json", true},
+		{"raw", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewRawWriter(filepath.Join(t.TempDir(), tt.name))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRawWriter(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+			if w != nil {
+				w.Close()
+			}
+		})
+	}
+}
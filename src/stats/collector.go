@@ -0,0 +1,371 @@
+package stats
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The first failureLogFirst failures with the same error are logged, then one in failureLogEvery.
+const (
+	failureLogFirst = 5
+	failureLogEvery = 1000
+)
+
+// Sink receives every result of a run as it is collected. Record is called from a goroutine of
+// the sink's own, results queue up in memory until the sink takes them, so a slow sink delays
+// neither aggregation nor the requests.
+type Sink interface {
+	Record(r Result)
+	Close() error // flushes the sink and reports the first error it ran into
+}
+
+// sinkQueue hands the results to one sink in order, from a goroutine of its own.
+type sinkQueue struct {
+	sink    Sink
+	mu      sync.Mutex
+	ready   *sync.Cond // signaled when results are pushed or the queue is closed
+	pending []Result
+	closed  bool
+	done    chan struct{} // closed once every result was handed to the sink
+}
+
+func newSinkQueue(sink Sink) *sinkQueue {
+	q := &sinkQueue{sink: sink, done: make(chan struct{})}
+	q.ready = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *sinkQueue) push(r Result) {
+	q.mu.Lock()
+	q.pending = append(q.pending, r)
+	q.mu.Unlock()
+	q.ready.Signal()
+}
+
+// close waits until the sink took every result pushed.
+func (q *sinkQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.ready.Signal()
+	<-q.done
+}
+
+func (q *sinkQueue) run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 && !q.closed {
+			q.ready.Wait()
+		}
+		batch := q.pending
+		q.pending = nil
+		q.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		for _, r := range batch {
+			q.sink.Record(r)
+		}
+	}
+}
+
+// Collector gathers the results of every request of a run and aggregates them into a Summary.
+// Executors feed it through Record from any goroutine, aggregation happens in a single goroutine.
+type Collector struct {
+	config  RunConfig
+	results chan Result
+	done    chan struct{}
+	started time.Time
+	sinks   []Sink
+	queues  []*sinkQueue // feeding the sinks, one per sink once started
+
+	dispatched atomic.Int64 // requests sent so far
+	completed  atomic.Int64 // requests aggregated so far
+
+	latencies   []time.Duration
+	uncorrected []time.Duration
+	firstTry    []time.Duration
+	attempts    int
+	retried     int
+	phases      phaseSamples
+	streams     streamSamples
+	sockets     socketSamples
+	successful  int
+	events      int
+	eventTypes  map[string]int
+	protocols   map[string]int
+	reconnects  int
+	missed      int
+	bytesIn     int64
+	bytesOut    int64
+	statuses    map[int]int
+	errors      map[string]int
+	classes     map[string]int
+	assertions  map[string]int           // failed assertions by expression
+	samples     map[string][]ErrorSample // first maxSamples failures of each class
+	maxSamples  int
+	failures    map[string]int // failures by error or status, for log sampling
+	interval    time.Duration  // width of the timeline buckets
+	timeline    []bucket
+}
+
+// bucket accumulates the requests that finished within one interval of the run.
+type bucket struct {
+	errors    int
+	events    int
+	latencies []time.Duration
+}
+
+func NewCollector(config RunConfig) *Collector {
+	return &Collector{
+		config:     config,
+		results:    make(chan Result, 1024),
+		done:       make(chan struct{}),
+		statuses:   make(map[int]int),
+		errors:     make(map[string]int),
+		classes:    make(map[string]int),
+		eventTypes: make(map[string]int),
+		protocols:  make(map[string]int),
+		assertions: make(map[string]int),
+		samples:    make(map[string][]ErrorSample),
+		maxSamples: 5,
+		failures:   make(map[string]int),
+		interval:   time.Second,
+	}
+}
+
+// AddSink registers a sink, sinks must be added before Start.
+func (c *Collector) AddSink(sink Sink) {
+	c.sinks = append(c.sinks, sink)
+}
+
+// SetErrorSamples sets how many failures of each error class are kept as samples, must be called before Start.
+func (c *Collector) SetErrorSamples(samples int) {
+	c.maxSamples = samples
+}
+
+// Start records the start time of the run and begins collecting results.
+func (c *Collector) Start() {
+	c.started = time.Now()
+	for _, sink := range c.sinks {
+		queue := newSinkQueue(sink)
+		c.queues = append(c.queues, queue)
+		go queue.run()
+	}
+	go c.collect()
+}
+
+// SetInterval sets the width of the timeline buckets, one second by default, must be called before Start.
+func (c *Collector) SetInterval(interval time.Duration) {
+	c.interval = interval
+}
+
+// Dispatch is called by executors when a request is sent, to track the requests in flight.
+func (c *Collector) Dispatch() {
+	c.dispatched.Add(1)
+}
+
+func (c *Collector) Record(r Result) {
+	c.results <- r
+}
+
+func (c *Collector) Config() RunConfig {
+	return c.config
+}
+
+// InFlight is the number of requests sent whose result has not been aggregated yet.
+func (c *Collector) InFlight() int64 {
+	inFlight := c.dispatched.Load() - c.completed.Load()
+	if inFlight < 0 {
+		return 0
+	}
+	return inFlight
+}
+
+// Stop waits for every recorded result to be aggregated, closes the sinks and returns the
+// run summary, nothing may be recorded after Stop.
+func (c *Collector) Stop() (*Summary, error) {
+	close(c.results)
+	<-c.done
+	summary := c.summarize(time.Now())
+	var err error = nil
+	for _, queue := range c.queues {
+		queue.close()
+		if closeErr := queue.sink.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return summary, err
+}
+
+func (c *Collector) collect() {
+	defer close(c.done)
+	for r := range c.results {
+		if !r.Successful && r.ErrorClass == "" {
+			r.ErrorClass = ErrorOther
+		}
+		c.log(r)
+		c.completed.Add(1)
+		for _, queue := range c.queues {
+			queue.push(r)
+		}
+
+		c.latencies = append(c.latencies, r.Latency())
+		c.uncorrected = append(c.uncorrected, r.UncorrectedLatency())
+		c.firstTry = append(c.firstTry, r.FirstTryLatency())
+		c.attempts += max(r.Attempts, 1)
+		if r.Attempts > 1 {
+			c.retried++
+		}
+		if r.Phases != nil {
+			c.phases.add(*r.Phases)
+		}
+		if r.Stream != nil {
+			c.streams.add(r.Stream, r.Events)
+		}
+		if r.Socket != nil {
+			c.sockets.add(r.Socket)
+		}
+		if r.Successful {
+			c.successful++
+		}
+		c.events += r.Events
+		for eventType, count := range r.EventTypes {
+			c.eventTypes[eventType] += count
+		}
+		c.reconnects += r.Reconnects
+		c.missed += r.MissedEvents
+		c.bytesIn += r.BytesIn
+		c.bytesOut += r.BytesOut
+		c.statuses[r.Status]++
+		if r.Proto != "" {
+			c.protocols[r.Proto]++
+		}
+		if !r.Successful {
+			c.addFailure(r)
+		}
+		c.addToTimeline(r)
+	}
+}
+
+func (c *Collector) addFailure(r Result) {
+	if r.Assertion != "" {
+		c.assertions[r.Assertion]++
+	} else if r.Error != "" {
+		c.errors[r.Error]++
+	}
+	c.classes[r.ErrorClass]++
+	if len(c.samples[r.ErrorClass]) < c.maxSamples {
+		c.samples[r.ErrorClass] = append(c.samples[r.ErrorClass], ErrorSample{
+			Worker: r.Worker,
+			Status: r.Status,
+			Error:  r.Error,
+			Body:   r.Body,
+		})
+	}
+}
+
+// log writes a debug line per result and warns about failures, sampled per error so a failing
+// target doesn't flood the output.
+func (c *Collector) log(r Result) {
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		slog.Debug("request", "worker", r.Worker, "request", r.Request, "latency", r.Latency(), "successful", r.Successful,
+			"status", StatusName(c.config.Protocol, r.Status), "events", r.Events, "error", r.Error)
+	}
+	if r.Successful {
+		return
+	}
+	key := r.Error
+	if r.Assertion != "" {
+		key = "assertion " + r.Assertion // the actual value differs from one failure to the next
+	} else if key == "" {
+		key = StatusName(c.config.Protocol, r.Status)
+	}
+	c.failures[key]++
+	count := c.failures[key]
+	if count <= failureLogFirst || count%failureLogEvery == 0 {
+		slog.Warn("request failed", "worker", r.Worker, "request", r.Request, "status", StatusName(c.config.Protocol, r.Status),
+			"class", r.ErrorClass, "error", cmp.Or(r.Error, key), "occurrences", count)
+	}
+	if count == failureLogFirst {
+		slog.Warn("sampling further failures with this error", "one_in", failureLogEvery, "error", key)
+	}
+}
+
+func (c *Collector) addToTimeline(r Result) {
+	index := int(r.End.Sub(c.started) / c.interval)
+	if index < 0 {
+		index = 0
+	}
+	for len(c.timeline) <= index {
+		c.timeline = append(c.timeline, bucket{})
+	}
+	b := &c.timeline[index]
+	if !r.Successful {
+		b.errors++
+	}
+	b.events += r.Events
+	b.latencies = append(b.latencies, r.Latency())
+}
+
+func (c *Collector) summarize(finished time.Time) *Summary {
+	duration := finished.Sub(c.started)
+	requests := len(c.latencies)
+	latency := NewDistribution(c.latencies) // sorts c.latencies for the curve below
+	var throughput float64 = 0
+	if duration > 0 {
+		throughput = float64(requests) / duration.Seconds()
+	}
+	return &Summary{
+		Config:             c.config,
+		Started:            c.started,
+		Finished:           finished,
+		Duration:           duration,
+		Requests:           requests,
+		Successful:         c.successful,
+		Failed:             requests - c.successful,
+		Throughput:         throughput,
+		Latency:            latency,
+		UncorrectedLatency: NewDistribution(c.uncorrected),
+		FirstTryLatency:    NewDistribution(c.firstTry),
+		Attempts:           c.attempts,
+		Retried:            c.retried,
+		Phases:             c.phases.breakdown(),
+		Streams:            c.streams.summary(),
+		Sockets:            c.sockets.summary(),
+		Events:             c.events,
+		EventTypes:         c.eventTypes,
+		Reconnects:         c.reconnects,
+		MissedEvents:       c.missed,
+		BytesIn:            c.bytesIn,
+		BytesOut:           c.bytesOut,
+		StatusCodes:        c.statuses,
+		Protocols:          c.protocols,
+		Errors:             c.errors,
+		ErrorClasses:       c.classes,
+		ErrorSamples:       c.samples,
+		AssertionFailures:  c.assertions,
+		LatencyCurve:       NewLatencyCurve(c.latencies),
+		Interval:           c.interval,
+		Timeline:           c.buckets(),
+	}
+}
+
+func (c *Collector) buckets() []Bucket {
+	buckets := make([]Bucket, len(c.timeline))
+	for i, b := range c.timeline {
+		buckets[i] = Bucket{
+			Offset:   time.Duration(i) * c.interval,
+			Requests: len(b.latencies),
+			Errors:   b.errors,
+			Events:   b.events,
+			Latency:  NewDistribution(b.latencies),
+		}
+	}
+	return buckets
+}
@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+// timelineResult ends at offset into the run, after latency.
+type timelineResult struct {
+	offset     time.Duration
+	latency    time.Duration
+	successful bool
+	events     int
+}
+
+// wantBucket is what a bucket of the timeline should hold, its latency the maximum.
+type wantBucket struct {
+	offset   time.Duration
+	requests int
+	errors   int
+	events   int
+	latency  time.Duration
+}
+
+func TestCollectorTimeline(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		interval time.Duration
+		results  []timelineResult
+		want     []wantBucket
+	}{
+		{"no results", time.Second, nil, []wantBucket{}},
+		{
+			"one second buckets",
+			time.Second,
+			[]timelineResult{
+				{100 * ms, 10 * ms, true, 0},
+				{999 * ms, 20 * ms, false, 0},
+				{1000 * ms, 30 * ms, true, 0},
+				{1500 * ms, 40 * ms, true, 0},
+			},
+			[]wantBucket{{0, 2, 1, 0, 20 * ms}, {time.Second, 2, 0, 0, 40 * ms}},
+		},
+		{
+			"empty buckets in between",
+			time.Second,
+			[]timelineResult{{200 * ms, 5 * ms, true, 1}, {3200 * ms, 7 * ms, true, 2}},
+			[]wantBucket{{0, 1, 0, 1, 5 * ms}, {time.Second, 0, 0, 0, 0}, {2 * time.Second, 0, 0, 0, 0}, {3 * time.Second, 1, 0, 2, 7 * ms}},
+		},
+		{
+			"custom interval",
+			250 * ms,
+			[]timelineResult{{100 * ms, ms, true, 0}, {300 * ms, 2 * ms, false, 0}, {600 * ms, 3 * ms, true, 0}, {700 * ms, 4 * ms, true, 0}},
+			[]wantBucket{{0, 1, 0, 0, ms}, {250 * ms, 1, 1, 0, 2 * ms}, {500 * ms, 2, 0, 0, 4 * ms}},
+		},
+		{
+			"results ending before the start go first",
+			time.Second,
+			[]timelineResult{{-50 * ms, 5 * ms, true, 0}},
+			[]wantBucket{{0, 1, 0, 0, 5 * ms}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(RunConfig{Protocol: ProtocolHttp})
+			c.SetInterval(tt.interval)
+			c.Start()
+			for _, r := range tt.results {
+				end := c.started.Add(r.offset)
+				c.Record(Result{Start: end.Add(-r.latency), End: end, Successful: r.successful, Status: 200, Events: r.events})
+			}
+			summary, err := c.Stop()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Interval != tt.interval {
+				t.Errorf("Interval = %v, want %v", summary.Interval, tt.interval)
+			}
+			if len(summary.Timeline) != len(tt.want) {
+				t.Fatalf("timeline has %d buckets, want %d", len(summary.Timeline), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := summary.Timeline[i]
+				if got.Offset != want.offset || got.Requests != want.requests || got.Errors != want.errors ||
+					got.Events != want.events || got.Latency.Max != want.latency {
+					t.Errorf("bucket %d = offset %v, %d requests, %d errors, %d events, max %v, want %+v",
+						i, got.Offset, got.Requests, got.Errors, got.Events, got.Latency.Max, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBucketRates(t *testing.T) {
+	tests := []struct {
+		bucket     Bucket
+		interval   time.Duration
+		throughput float64
+		errorRate  float64
+	}{
+		{Bucket{Requests: 10, Errors: 1}, time.Second, 10, 0.1},
+		{Bucket{Requests: 10, Errors: 5}, 500 * time.Millisecond, 20, 0.5},
+		{Bucket{}, time.Second, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.bucket.Throughput(tt.interval); got != tt.throughput {
+			t.Errorf("%+v Throughput(%v) = %g, want %g", tt.bucket, tt.interval, got, tt.throughput)
+		}
+		if got := tt.bucket.ErrorRate(); got != tt.errorRate {
+			t.Errorf("%+v ErrorRate() = %g, want %g", tt.bucket, got, tt.errorRate)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package stats
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Failure classes of a request, reported per class with sample messages in the run summary.
+const (
+	ErrorDns            = "dns"
+	ErrorConnectRefused = "connect_refused"
+	ErrorTls            = "tls"
+	ErrorTimeout        = "timeout"
+	ErrorReset          = "connection_reset"
+	ErrorHttp4xx        = "http_4xx"
+	ErrorHttp5xx        = "http_5xx"
+	ErrorHttpStatus     = "http_status" // any other status outside 2xx
+	ErrorBodyRead       = "body_read"
+	ErrorAssertion      = "assertion"
+	ErrorWsClose        = "ws_close" // the server closed a WebSocket with an error code
+	ErrorRequest        = "request"  // the request could not be built, e.g. the upload file is missing
+	ErrorOther          = "other"
+)
+
+// ClassifyError names the class of a transport level error.
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorDns
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorConnectRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorReset
+	}
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) ||
+		strings.Contains(err.Error(), "tls: ") {
+		return ErrorTls
+	}
+	return ErrorOther
+}
+
+// ClassifyHttpStatus names the class of a response status, empty for 2xx.
+func ClassifyHttpStatus(code int) string {
+	switch {
+	case code >= 200 && code < 300:
+		return ""
+	case code >= 400 && code < 500:
+		return ErrorHttp4xx
+	case code >= 500 && code < 600:
+		return ErrorHttp5xx
+	}
+	return ErrorHttpStatus
+}
+
+// ClassifyGrpcError names the class of a failed RPC. Transport failures surface as Unavailable,
+// their class is recovered from the message, other statuses are classed by code, e.g. grpc_not_found.
+func ClassifyGrpcError(err error) string {
+	s, ok := status.FromError(err)
+	if !ok {
+		return ClassifyError(err)
+	}
+	message := s.Message()
+	switch s.Code() {
+	case codes.DeadlineExceeded:
+		return ErrorTimeout
+	case codes.Unavailable:
+		switch {
+		case strings.Contains(message, "connection refused"):
+			return ErrorConnectRefused
+		case strings.Contains(message, "no such host"):
+			return ErrorDns
+		case strings.Contains(message, "tls:") || strings.Contains(message, "x509:") || strings.Contains(message, "handshake"):
+			return ErrorTls
+		case strings.Contains(message, "connection reset") || strings.Contains(message, "EOF"):
+			return ErrorReset
+		}
+	}
+	return "grpc_" + snakeCase(s.Code().String())
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// timeoutError is a net.Error that timed out, as returned by a deadline on a connection.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	dial := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"dns", &net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}, ErrorDns},
+		{"context deadline", fmt.Errorf("Get \"http://x\": %w", context.DeadlineExceeded), ErrorTimeout},
+		{"net timeout", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, ErrorTimeout},
+		{"refused", dial(syscall.ECONNREFUSED), ErrorConnectRefused},
+		{"reset", dial(syscall.ECONNRESET), ErrorReset},
+		{"broken pipe", dial(syscall.EPIPE), ErrorReset},
+		{"eof", fmt.Errorf("Post \"http://x\": %w", io.EOF), ErrorReset},
+		{"unexpected eof", io.ErrUnexpectedEOF, ErrorReset},
+		{"tls alert", fmt.Errorf("remote error: %w", tls.AlertError(40)), ErrorTls},
+		{"unknown authority", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, ErrorTls},
+		{"tls message", errors.New("tls: first record does not look like a TLS handshake"), ErrorTls},
+		{"other", errors.New("something else"), ErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyHttpStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{200, ""},
+		{204, ""},
+		{301, ErrorHttpStatus},
+		{404, ErrorHttp4xx},
+		{429, ErrorHttp4xx},
+		{503, ErrorHttp5xx},
+		{0, ErrorHttpStatus},
+	}
+	for _, tt := range tests {
+		if got := ClassifyHttpStatus(tt.code); got != tt.want {
+			t.Errorf("ClassifyHttpStatus(%d) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyGrpcError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"deadline", status.Error(codes.DeadlineExceeded, "context deadline exceeded"), ErrorTimeout},
+		{"refused", status.Error(codes.Unavailable, "connection error: desc = \"transport: Error while dialing: dial tcp 127.0.0.1:1: connect: connection refused\""), ErrorConnectRefused},
+		{"dns", status.Error(codes.Unavailable, "name resolver error: produced zero addresses: lookup x: no such host"), ErrorDns},
+		{"tls", status.Error(codes.Unavailable, "connection error: desc = \"transport: authentication handshake failed: x509: certificate signed by unknown authority\""), ErrorTls},
+		{"reset", status.Error(codes.Unavailable, "error reading from server: EOF"), ErrorReset},
+		{"unavailable", status.Error(codes.Unavailable, "the server is shutting down"), "grpc_unavailable"},
+		{"not found", status.Error(codes.NotFound, "no such user"), "grpc_not_found"},
+		{"resource exhausted", status.Error(codes.ResourceExhausted, "slow down"), "grpc_resource_exhausted"},
+		{"not a status", fmt.Errorf("wrapped: %w", io.EOF), ErrorReset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyGrpcError(tt.err); got != tt.want {
+				t.Errorf("ClassifyGrpcError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Phases breaks the latency of an HTTP request down, as traced on its last attempt. The connection
+// phases are zero when they didn't happen, such as on a reused connection.
+type Phases struct {
+	Dns      time.Duration
+	Connect  time.Duration
+	Tls      time.Duration
+	Wait     time.Duration // from the request written to the first response byte, the server processing time
+	Ttfb     time.Duration // from the start of the attempt to the first response byte, every phase above included
+	Transfer time.Duration // from the first response byte to the end of the body
+	Reused   bool          // whether the connection was kept alive from an earlier request
+	Resumed  bool          // whether the TLS handshake resumed an earlier session
+}
+
+// PhaseBreakdown summarizes the phases of the traced requests of a run, the connection phases
+// over the requests that opened a connection.
+type PhaseBreakdown struct {
+	Traced       int // requests that got a response and were traced
+	Reused       int
+	Resumed      int // TLS handshakes that resumed a session, out of TlsCount
+	DnsCount     int
+	ConnectCount int
+	TlsCount     int
+	Dns          Distribution
+	Connect      Distribution
+	Tls          Distribution
+	Wait         Distribution
+	Ttfb         Distribution
+	Transfer     Distribution
+}
+
+// ReuseRate is the fraction of the traced requests sent over a kept alive connection.
+func (p PhaseBreakdown) ReuseRate() float64 {
+	if p.Traced == 0 {
+		return 0
+	}
+	return float64(p.Reused) / float64(p.Traced)
+}
+
+// ResumeRate is the fraction of the TLS handshakes that resumed an earlier session.
+func (p PhaseBreakdown) ResumeRate() float64 {
+	if p.TlsCount == 0 {
+		return 0
+	}
+	return float64(p.Resumed) / float64(p.TlsCount)
+}
+
+// PhaseRow is one phase of the breakdown, in the order phases happen.
+type PhaseRow struct {
+	Name    string
+	Count   int
+	Latency Distribution
+}
+
+func (p PhaseBreakdown) Rows() []PhaseRow {
+	return []PhaseRow{
+		{"dns", p.DnsCount, p.Dns},
+		{"connect", p.ConnectCount, p.Connect},
+		{"tls", p.TlsCount, p.Tls},
+		{"server", p.Traced, p.Wait},
+		{"ttfb", p.Traced, p.Ttfb},
+		{"transfer", p.Traced, p.Transfer},
+	}
+}
+
+func (p PhaseBreakdown) print(w io.Writer) {
+	fmt.Fprintln(w, "Latency phases p50 / p90 / p99 (ms):")
+	for _, row := range p.Rows() {
+		if row.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-9s %8.3f / %8.3f / %8.3f  (%d requests)\n", row.Name,
+			milliseconds(row.Latency.P50), milliseconds(row.Latency.P90), milliseconds(row.Latency.P99), row.Count)
+	}
+	fmt.Fprintf(w, "Connection reuse: %.2f%% (%d of %d requests)\n", p.ReuseRate()*100, p.Reused, p.Traced)
+	if p.TlsCount > 0 {
+		fmt.Fprintf(w, "TLS session resumption: %.2f%% (%d of %d handshakes)\n", p.ResumeRate()*100, p.Resumed, p.TlsCount)
+	}
+}
+
+// phaseSamples collects the phases of the traced results until the run is summarized.
+type phaseSamples struct {
+	traced   int
+	reused   int
+	resumed  int
+	dns      []time.Duration
+	connect  []time.Duration
+	tls      []time.Duration
+	wait     []time.Duration
+	ttfb     []time.Duration
+	transfer []time.Duration
+}
+
+func (s *phaseSamples) add(p Phases) {
+	s.traced++
+	if p.Reused {
+		s.reused++
+	}
+	if p.Dns > 0 {
+		s.dns = append(s.dns, p.Dns)
+	}
+	if p.Connect > 0 {
+		s.connect = append(s.connect, p.Connect)
+	}
+	if p.Tls > 0 {
+		s.tls = append(s.tls, p.Tls)
+		if p.Resumed {
+			s.resumed++
+		}
+	}
+	s.wait = append(s.wait, p.Wait)
+	s.ttfb = append(s.ttfb, p.Ttfb)
+	s.transfer = append(s.transfer, p.Transfer)
+}
+
+func (s *phaseSamples) breakdown() PhaseBreakdown {
+	return PhaseBreakdown{
+		Traced:       s.traced,
+		Reused:       s.reused,
+		Resumed:      s.resumed,
+		DnsCount:     len(s.dns),
+		ConnectCount: len(s.connect),
+		TlsCount:     len(s.tls),
+		Dns:          NewDistribution(s.dns),
+		Connect:      NewDistribution(s.connect),
+		Tls:          NewDistribution(s.tls),
+		Wait:         NewDistribution(s.wait),
+		Ttfb:         NewDistribution(s.ttfb),
+		Transfer:     NewDistribution(s.transfer),
+	}
+}
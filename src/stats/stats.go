@@ -0,0 +1,102 @@
+package stats
+
+import "time"
+
+const (
+	ProtocolHttp      = "http"
+	ProtocolGrpc      = "grpc"
+	ProtocolWebsocket = "websocket"
+
+	ModeUnary           = "unary"
+	ModeSse             = "sse"
+	ModeClientStreaming = "client_streaming"
+	ModeServerStreaming = "server_streaming"
+	ModeBidiStreaming   = "bidi_streaming"
+)
+
+const (
+	LatencyCorrected   = "corrected"
+	LatencyUncorrected = "uncorrected"
+	LatencyBoth        = "both"
+)
+
+func ValidLatencyMode(mode string) bool {
+	return mode == LatencyCorrected || mode == LatencyUncorrected || mode == LatencyBoth
+}
+
+// BodySampleSize is how much of the response of a failed request is kept in Result.Body.
+const BodySampleSize = 512
+
+// Result is the outcome of a single request, shared by every HTTP and gRPC mode.
+type Result struct {
+	Worker       int       // index of the worker of the pool that sent the request
+	Request      int       // index of the request within the run
+	Intended     time.Time // when the request was scheduled to be sent
+	Start        time.Time // when the request was actually sent
+	End          time.Time
+	Successful   bool
+	Status       int    // HTTP status code or gRPC status code, 0 when no response arrived over HTTP
+	Proto        string // HTTP version the response came with, e.g. HTTP/2.0, empty without a response
+	BytesOut     int64
+	BytesIn      int64
+	Events       int            // SSE events or server streamed messages
+	EventTypes   map[string]int // SSE events by event type, nil for other modes
+	LastEventId  string         // id of the last SSE event received
+	Reconnects   int            // times a held SSE subscriber reconnected, see --hold
+	MissedEvents int            // SSE events skipped between consecutive numeric event ids
+	ErrorClass   string         // why the request failed, one of the Error* classes
+	Error        string
+	Body         string        // start of the response body of a failed request, kept for error samples
+	Assertion    string        // the --assert expression the response failed, counted apart from transport errors
+	Attempts     int           // attempts made including retries, 0 in modes that are never retried
+	FirstTryEnd  time.Time     // when the first attempt ended, zero in modes that are never retried
+	Phases       *Phases       // latency breakdown of HTTP requests that got a response, nil otherwise
+	Stream       *StreamTiming // timings of SSE and server streams that got their headers, nil otherwise
+	Socket       *Socket       // messages of WebSocket connections that completed their handshake, nil otherwise
+}
+
+// Latency is measured from the intended send time, so it is corrected for coordinated omission.
+func (r Result) Latency() time.Duration {
+	if r.Intended.IsZero() {
+		return r.UncorrectedLatency()
+	}
+	return r.End.Sub(r.Intended)
+}
+
+// FirstTryLatency is the latency of the first attempt alone, as if the request had never been retried.
+func (r Result) FirstTryLatency() time.Duration {
+	if r.FirstTryEnd.IsZero() {
+		return r.Latency()
+	}
+	if r.Intended.IsZero() {
+		return r.FirstTryEnd.Sub(r.Start)
+	}
+	return r.FirstTryEnd.Sub(r.Intended)
+}
+
+// UncorrectedLatency is measured from the moment the request actually went out.
+func (r Result) UncorrectedLatency() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// RunConfig describes the run a collector gathers results for.
+type RunConfig struct {
+	RunId       string  `json:"run_id,omitempty"` // identifies the run in exported metrics
+	Protocol    string  `json:"protocol"`         // http, grpc or websocket
+	Mode        string  `json:"mode"`             // unary, sse, client_streaming, server_streaming or bidi_streaming
+	Target      string  `json:"target"`
+	Method      string  `json:"method"`
+	Requests    int     `json:"requests"`
+	Concurrency int     `json:"concurrency"`
+	Timeout     int     `json:"timeout_seconds"`
+	MaxRetries  int     `json:"max_retries"`
+	Rate        float64 `json:"rate"`
+	LatencyMode string  `json:"latency_mode"`
+	HttpVersion string  `json:"http_version,omitempty"` // HTTP versions the client was allowed to use: auto, http1, http2 or h2c
+	HoldSeconds float64 `json:"hold_seconds,omitempty"` // how long SSE subscribers were held open, 0 opens each stream once
+}
+
+// Streaming reports whether the requests of the run receive a stream of events or messages.
+func (c RunConfig) Streaming() bool {
+	return c.Mode == ModeSse || c.Mode == ModeServerStreaming || c.Mode == ModeBidiStreaming
+}
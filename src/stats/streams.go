@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// StreamTiming times one SSE or server streaming response, measured from when the stream was opened.
+// Executors call Headers and Event as the stream progresses and Finish once it ended.
+type StreamTiming struct {
+	FirstByte  time.Duration   // until the response headers arrived
+	FirstEvent time.Duration   // until the first event or message, 0 when none arrived
+	Gaps       []time.Duration // between consecutive events
+	Duration   time.Duration   // until the stream ended
+
+	start time.Time
+	last  time.Time
+}
+
+func NewStreamTiming() *StreamTiming {
+	return &StreamTiming{start: time.Now()}
+}
+
+func (t *StreamTiming) Headers() {
+	t.FirstByte = time.Since(t.start)
+}
+
+func (t *StreamTiming) Event() {
+	now := time.Now()
+	if t.last.IsZero() {
+		t.FirstEvent = now.Sub(t.start)
+	} else {
+		t.Gaps = append(t.Gaps, now.Sub(t.last))
+	}
+	t.last = now
+}
+
+func (t *StreamTiming) Finish() {
+	t.Duration = time.Since(t.start)
+}
+
+// EventRate is the number of events per second over the whole stream.
+func (t *StreamTiming) EventRate(events int) float64 {
+	if t.Duration <= 0 {
+		return 0
+	}
+	return float64(events) / t.Duration.Seconds()
+}
+
+// RateDistribution holds the percentiles of a rate measured per stream.
+type RateDistribution struct {
+	Min  float64
+	Mean float64
+	P50  float64
+	P90  float64
+	P99  float64
+	Max  float64
+}
+
+// NewRateDistribution sorts rates in place and computes their percentiles.
+func NewRateDistribution(rates []float64) RateDistribution {
+	if len(rates) == 0 {
+		return RateDistribution{}
+	}
+	sort.Float64s(rates)
+	var total float64 = 0
+	for _, r := range rates {
+		total += r
+	}
+	return RateDistribution{
+		Min:  rates[0],
+		Mean: total / float64(len(rates)),
+		P50:  PercentileFloat(rates, 50),
+		P90:  PercentileFloat(rates, 90),
+		P99:  PercentileFloat(rates, 99),
+		Max:  rates[len(rates)-1],
+	}
+}
+
+// StreamSummary summarizes the timings of the streams of a run that got their headers.
+type StreamSummary struct {
+	Streams    int
+	FirstByte  Distribution
+	FirstEvent Distribution // over the streams that received an event
+	Gap        Distribution // over every pair of consecutive events of every stream
+	EventRate  RateDistribution
+}
+
+func (s StreamSummary) print(w io.Writer) {
+	fmt.Fprintf(w, "Streams p50 / p90 / p99 (ms) over %d streams:\n", s.Streams)
+	fmt.Fprintf(w, "  first byte  %8.3f / %8.3f / %8.3f\n", milliseconds(s.FirstByte.P50), milliseconds(s.FirstByte.P90), milliseconds(s.FirstByte.P99))
+	fmt.Fprintf(w, "  first event %8.3f / %8.3f / %8.3f\n", milliseconds(s.FirstEvent.P50), milliseconds(s.FirstEvent.P90), milliseconds(s.FirstEvent.P99))
+	fmt.Fprintf(w, "  event gap   %8.3f / %8.3f / %8.3f\n", milliseconds(s.Gap.P50), milliseconds(s.Gap.P90), milliseconds(s.Gap.P99))
+	fmt.Fprintf(w, "Events per second per stream p50 / p90 / p99: %.2f / %.2f / %.2f\n", s.EventRate.P50, s.EventRate.P90, s.EventRate.P99)
+}
+
+// streamSamples collects the timings of the streams until the run is summarized.
+type streamSamples struct {
+	firstByte  []time.Duration
+	firstEvent []time.Duration
+	gaps       []time.Duration
+	rates      []float64
+}
+
+func (s *streamSamples) add(t *StreamTiming, events int) {
+	s.firstByte = append(s.firstByte, t.FirstByte)
+	if events > 0 {
+		s.firstEvent = append(s.firstEvent, t.FirstEvent)
+	}
+	s.gaps = append(s.gaps, t.Gaps...)
+	s.rates = append(s.rates, t.EventRate(events))
+}
+
+func (s *streamSamples) summary() StreamSummary {
+	return StreamSummary{
+		Streams:    len(s.firstByte),
+		FirstByte:  NewDistribution(s.firstByte),
+		FirstEvent: NewDistribution(s.firstEvent),
+		Gap:        NewDistribution(s.gaps),
+		EventRate:  NewRateDistribution(s.rates),
+	}
+}
@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamTiming(t *testing.T) {
+	const pause = 10 * time.Millisecond
+	tests := []struct {
+		name   string
+		events int
+	}{
+		{"no events", 0},
+		{"one event", 1},
+		{"several events", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timing := NewStreamTiming()
+			time.Sleep(pause)
+			timing.Headers()
+			for i := 0; i < tt.events; i++ {
+				time.Sleep(pause)
+				timing.Event()
+			}
+			timing.Finish()
+
+			if timing.FirstByte < pause {
+				t.Errorf("FirstByte = %v, want at least %v", timing.FirstByte, pause)
+			}
+			if tt.events == 0 && timing.FirstEvent != 0 {
+				t.Errorf("FirstEvent = %v without events, want 0", timing.FirstEvent)
+			}
+			if tt.events > 0 && timing.FirstEvent < timing.FirstByte+pause {
+				t.Errorf("FirstEvent = %v, want at least %v after the headers at %v", timing.FirstEvent, pause, timing.FirstByte)
+			}
+			if want := max(tt.events-1, 0); len(timing.Gaps) != want {
+				t.Fatalf("%d gaps between %d events, want %d", len(timing.Gaps), tt.events, want)
+			}
+			for i, gap := range timing.Gaps {
+				if gap < pause {
+					t.Errorf("gap %d = %v, want at least %v", i, gap, pause)
+				}
+			}
+			if timing.Duration < timing.FirstByte+time.Duration(tt.events)*pause {
+				t.Errorf("Duration = %v, shorter than the stream", timing.Duration)
+			}
+		})
+	}
+}
+
+func TestEventRate(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		events   int
+		want     float64
+	}{
+		{2 * time.Second, 10, 5},
+		{500 * time.Millisecond, 10, 20},
+		{time.Second, 0, 0},
+		{0, 10, 0},
+	}
+	for _, tt := range tests {
+		timing := &StreamTiming{Duration: tt.duration}
+		if got := timing.EventRate(tt.events); got != tt.want {
+			t.Errorf("EventRate(%d) over %v = %g, want %g", tt.events, tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestStreamSummary(t *testing.T) {
+	ms := time.Millisecond
+	c := NewCollector(RunConfig{Protocol: ProtocolHttp, Mode: ModeSse})
+	c.Start()
+	for _, r := range []Result{
+		{Successful: true, Events: 3, Stream: &StreamTiming{FirstByte: 5 * ms, FirstEvent: 10 * ms, Gaps: []time.Duration{20 * ms, 40 * ms}, Duration: time.Second}},
+		{Successful: true, Events: 1, Stream: &StreamTiming{FirstByte: 15 * ms, FirstEvent: 30 * ms, Duration: 500 * ms}},
+		{Successful: true, Events: 0, Stream: &StreamTiming{FirstByte: 25 * ms, Duration: 2 * time.Second}},
+		{Events: 0}, // never got its headers
+	} {
+		c.Record(r)
+	}
+	summary, err := c.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := summary.Streams
+	if s.Streams != 3 {
+		t.Errorf("Streams = %d, want the 3 that got their headers", s.Streams)
+	}
+	if s.FirstByte.Min != 5*ms || s.FirstByte.Max != 25*ms {
+		t.Errorf("first byte = %v to %v, want 5ms to 25ms", s.FirstByte.Min, s.FirstByte.Max)
+	}
+	if s.FirstEvent.Min != 10*ms || s.FirstEvent.Max != 30*ms || s.FirstEvent.Mean != 20*ms {
+		t.Errorf("first event = %v to %v mean %v, want 10ms to 30ms mean 20ms over the streams with events",
+			s.FirstEvent.Min, s.FirstEvent.Max, s.FirstEvent.Mean)
+	}
+	if s.Gap.Min != 20*ms || s.Gap.Max != 40*ms || s.Gap.Mean != 30*ms {
+		t.Errorf("gap = %v to %v mean %v, want 20ms to 40ms mean 30ms", s.Gap.Min, s.Gap.Max, s.Gap.Mean)
+	}
+	if want := (RateDistribution{Min: 0, Mean: 5.0 / 3, P50: 2, P90: 3, P99: 3, Max: 3}); s.EventRate != want {
+		t.Errorf("event rate = %+v, want %+v", s.EventRate, want)
+	}
+}
+
+func TestNewRateDistribution(t *testing.T) {
+	tests := []struct {
+		name  string
+		rates []float64
+		want  RateDistribution
+	}{
+		{"empty", nil, RateDistribution{}},
+		{"single", []float64{4}, RateDistribution{Min: 4, Mean: 4, P50: 4, P90: 4, P99: 4, Max: 4}},
+		{"unsorted", []float64{10, 1, 7, 4}, RateDistribution{Min: 1, Mean: 5.5, P50: 4, P90: 10, P99: 10, Max: 10}},
+		{"ten", []float64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, RateDistribution{Min: 1, Mean: 5.5, P50: 5, P90: 9, P99: 10, Max: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRateDistribution(tt.rates); got != tt.want {
+				t.Errorf("NewRateDistribution() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
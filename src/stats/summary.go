@@ -0,0 +1,359 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Summary is the aggregated outcome of a whole run.
+type Summary struct {
+	Config             RunConfig
+	Started            time.Time
+	Finished           time.Time
+	Duration           time.Duration
+	Requests           int
+	Successful         int
+	Failed             int
+	Throughput         float64 // completed requests per second
+	Latency            Distribution
+	UncorrectedLatency Distribution
+	FirstTryLatency    Distribution // latency of the first attempts, as if nothing had been retried
+	Attempts           int          // attempts made, retries included
+	Retried            int          // requests that needed more than one attempt
+	Phases             PhaseBreakdown
+	Streams            StreamSummary
+	Sockets            SocketSummary
+	Events             int
+	EventTypes         map[string]int // SSE events by event type
+	Reconnects         int            // reconnections of held SSE subscribers
+	MissedEvents       int            // SSE events skipped according to their numeric ids
+	BytesIn            int64
+	BytesOut           int64
+	StatusCodes        map[int]int
+	Protocols          map[string]int // HTTP responses by the HTTP version they came with
+	Errors             map[string]int // failed requests by error
+	ErrorClasses       map[string]int // failed requests by error class
+	ErrorSamples       map[string][]ErrorSample
+	AssertionFailures  map[string]int // responses that failed an --assert check, by expression
+	LatencyCurve       []PercentilePoint
+	Interval           time.Duration // width of the timeline buckets
+	Timeline           []Bucket
+	Thresholds         []ThresholdResult // set once the run is evaluated against --threshold
+}
+
+// ErrorSample is one failed request kept to show what a class of errors looks like.
+type ErrorSample struct {
+	Worker int
+	Status int
+	Error  string
+	Body   string // start of the response body, when there was one
+}
+
+// Bucket holds the requests that finished within one interval of the run.
+type Bucket struct {
+	Offset   time.Duration // start of the interval relative to the run start
+	Requests int
+	Errors   int
+	Events   int
+	Latency  Distribution
+}
+
+// Throughput is the number of requests per second that finished within the bucket.
+func (b Bucket) Throughput(interval time.Duration) float64 {
+	return float64(b.Requests) / interval.Seconds()
+}
+
+func (b Bucket) ErrorRate() float64 {
+	if b.Requests == 0 {
+		return 0
+	}
+	return float64(b.Errors) / float64(b.Requests)
+}
+
+type PercentilePoint struct {
+	Percentile float64
+	Latency    time.Duration
+}
+
+type Distribution struct {
+	Min  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+	P999 time.Duration
+	Max  time.Duration
+}
+
+// NewDistribution sorts latencies in place and computes their percentiles.
+func NewDistribution(latencies []time.Duration) Distribution {
+	if len(latencies) == 0 {
+		return Distribution{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration = 0
+	for _, l := range latencies {
+		total += l
+	}
+	return Distribution{
+		Min:  latencies[0],
+		Mean: total / time.Duration(len(latencies)),
+		P50:  Percentile(latencies, 50),
+		P90:  Percentile(latencies, 90),
+		P95:  Percentile(latencies, 95),
+		P99:  Percentile(latencies, 99),
+		P999: Percentile(latencies, 99.9),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+var curvePercentiles = []float64{0, 10, 25, 50, 75, 90, 95, 99, 99.9, 99.99, 100}
+
+// NewLatencyCurve computes the latency at a fixed set of percentiles from sorted latencies,
+// used to plot the latency distribution.
+func NewLatencyCurve(sorted []time.Duration) []PercentilePoint {
+	if len(sorted) == 0 {
+		return nil
+	}
+	curve := make([]PercentilePoint, len(curvePercentiles))
+	for i, p := range curvePercentiles {
+		curve[i] = PercentilePoint{Percentile: p, Latency: Percentile(sorted, p)}
+	}
+	return curve
+}
+
+// Percentile uses the nearest-rank method on an already sorted slice.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[percentileRank(len(sorted), p)-1]
+}
+
+// PercentileFloat is Percentile of sorted values such as rates.
+func PercentileFloat(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[percentileRank(len(sorted), p)-1]
+}
+
+// percentileRank is the 1-based rank of the value at percentile p of n sorted values, the nearest rank method.
+func percentileRank(n int, p float64) int {
+	rank := int(math.Ceil(p * float64(n) / 100)) // p / 100 first would round 99.9% of 1000 up
+	if rank < 1 {
+		rank = 1
+	}
+	return rank
+}
+
+func (s *Summary) SuccessRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Successful) / float64(s.Requests)
+}
+
+func (s *Summary) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(s.Requests)
+}
+
+func (s *Summary) Streaming() bool {
+	return s.Config.Streaming()
+}
+
+// StatusName renders a status code the way the run's protocol names it.
+func (s *Summary) StatusName(code int) string {
+	return StatusName(s.Config.Protocol, code)
+}
+
+func StatusName(protocol string, code int) string {
+	if protocol == ProtocolGrpc {
+		return codes.Code(code).String()
+	}
+	if code == 0 {
+		return "no response"
+	}
+	return fmt.Sprint(code)
+}
+
+// Print writes the human readable summary of the run.
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprint(w, "\n############################################  Final Results  ############################################\n\n")
+	fmt.Fprintf(w, "Total number of requests: %d\n", s.Requests)
+	fmt.Fprintf(w, "Total Success percent: %.2f%%\n", s.SuccessRate()*100)
+	fmt.Fprintf(w, "Average Latency: %.3f Second\n", s.Latency.Mean.Seconds())
+	if s.Config.LatencyMode != LatencyUncorrected {
+		s.Latency.print(w, "Corrected")
+	}
+	if s.Config.LatencyMode == LatencyUncorrected || s.Config.LatencyMode == LatencyBoth {
+		s.UncorrectedLatency.print(w, "Uncorrected")
+	}
+	if s.Config.MaxRetries > 0 {
+		fmt.Fprintf(w, "Retried requests: %d (%.2f%%), %.3f attempts per request\n", s.Retried, s.RetryRate()*100, s.AttemptsPerRequest())
+		s.FirstTryLatency.print(w, "First try")
+	}
+	if s.Phases.Traced > 0 {
+		s.Phases.print(w)
+	}
+	if s.Streaming() && s.Requests > 0 {
+		fmt.Fprintf(w, "Average Events: %d\n", s.Events/s.Requests)
+	}
+	if len(s.EventTypes) > 0 {
+		fmt.Fprintf(w, "Events by type: %s\n", CountLine(s.EventTypes))
+	}
+	if s.Streams.Streams > 0 {
+		s.Streams.print(w)
+	}
+	if s.Sockets.Connections > 0 {
+		s.Sockets.print(w)
+	}
+	if s.Config.HoldSeconds > 0 {
+		fmt.Fprintf(w, "Subscribers held %s: %d reconnects, %d missed events\n", time.Duration(s.Config.HoldSeconds*float64(time.Second)), s.Reconnects, s.MissedEvents)
+	}
+	fmt.Fprintf(w, "Status codes: %s\n", s.statusLine())
+	if len(s.Protocols) > 0 {
+		fmt.Fprintf(w, "Protocols: %s\n", CountLine(s.Protocols))
+	}
+	messages := make([]string, 0, len(s.Errors))
+	for message := range s.Errors {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+	for _, message := range messages {
+		fmt.Fprintf(w, "Error (%d): %s\n", s.Errors[message], message)
+	}
+	if failed := s.AssertionFailed(); failed > 0 {
+		fmt.Fprintf(w, "Assertion failures: %d\n", failed)
+		expressions := make([]string, 0, len(s.AssertionFailures))
+		for expression := range s.AssertionFailures {
+			expressions = append(expressions, expression)
+		}
+		sort.Strings(expressions)
+		for _, expression := range expressions {
+			fmt.Fprintf(w, "Assertion failed (%d): %s\n", s.AssertionFailures[expression], expression)
+		}
+	}
+	for _, class := range s.ErrorClassNames() {
+		fmt.Fprintf(w, "Error class %s (%d)\n", class, s.ErrorClasses[class])
+		for _, sample := range s.ErrorSamples[class] {
+			line := fmt.Sprintf("  sample: %s", sample.Error)
+			if sample.Body != "" {
+				line += fmt.Sprintf(", body: %q", sample.Body)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	fmt.Fprintf(w, "Bytes sent: %d, received: %d\n", s.BytesOut, s.BytesIn)
+	fmt.Fprintf(w, "Total time taken: %.4f Second\n", s.Duration.Seconds())
+	fmt.Fprintf(w, "Total throughput: %.4f Request/Second\n", s.Throughput)
+}
+
+// PrintTimeline writes the per-interval buckets of the run as a table.
+func (s *Summary) PrintTimeline(w io.Writer) {
+	fmt.Fprintf(w, "\nTimeline (%s buckets, latency in ms)\n", s.Interval)
+	header := fmt.Sprintf("%10s %9s %9s %7s %9s %9s %9s %9s", "offset", "requests", "req/s", "errors", "p50", "p90", "p99", "max")
+	if s.Streaming() {
+		header += fmt.Sprintf(" %9s", "events")
+	}
+	fmt.Fprintln(w, header)
+	for _, b := range s.Timeline {
+		line := fmt.Sprintf("%10s %9d %9.1f %7d %9.2f %9.2f %9.2f %9.2f", b.Offset, b.Requests, b.Throughput(s.Interval), b.Errors,
+			milliseconds(b.Latency.P50), milliseconds(b.Latency.P90), milliseconds(b.Latency.P99), milliseconds(b.Latency.Max))
+		if s.Streaming() {
+			line += fmt.Sprintf(" %9d", b.Events)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (d Distribution) print(w io.Writer, label string) {
+	fmt.Fprintf(w, "%s Latency p50: %.3f p90: %.3f p99: %.3f p99.9: %.3f max: %.3f Second\n",
+		label, d.P50.Seconds(), d.P90.Seconds(), d.P99.Seconds(), d.P999.Seconds(), d.Max.Seconds())
+}
+
+// RetryRate is the fraction of requests that were retried at least once.
+func (s *Summary) RetryRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Retried) / float64(s.Requests)
+}
+
+// AttemptsPerRequest is the average number of attempts a request took.
+func (s *Summary) AttemptsPerRequest() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Attempts) / float64(s.Requests)
+}
+
+// AssertionFailed is the number of requests that got a response but failed an assertion on it.
+func (s *Summary) AssertionFailed() int {
+	failed := 0
+	for _, count := range s.AssertionFailures {
+		failed += count
+	}
+	return failed
+}
+
+// ErrorClassNames lists the error classes of the run, most frequent first.
+func (s *Summary) ErrorClassNames() []string {
+	classes := make([]string, 0, len(s.ErrorClasses))
+	for class := range s.ErrorClasses {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if s.ErrorClasses[classes[i]] != s.ErrorClasses[classes[j]] {
+			return s.ErrorClasses[classes[i]] > s.ErrorClasses[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+	return classes
+}
+
+// CountLine renders counts by name, most frequent first, e.g. "HTTP/2.0: 98, HTTP/1.1: 2".
+func CountLine(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %d", name, counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (s *Summary) statusLine() string {
+	keys := make([]int, 0, len(s.StatusCodes))
+	for code := range s.StatusCodes {
+		keys = append(keys, code)
+	}
+	sort.Ints(keys)
+	parts := make([]string, 0, len(keys))
+	for _, code := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %d", s.StatusName(code), s.StatusCodes[code]))
+	}
+	return strings.Join(parts, ", ")
+}
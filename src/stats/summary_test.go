@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+// milliseconds1To returns the latencies 1ms, 2ms, ... n ms in reverse order.
+func milliseconds1To(n int) []time.Duration {
+	latencies := make([]time.Duration, n)
+	for i := range latencies {
+		latencies[i] = time.Duration(n-i) * time.Millisecond
+	}
+	return latencies
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 50, 0},
+		{"single", []time.Duration{7}, 99, 7},
+		{"p0 is the minimum", sorted, 0, 10},
+		{"p50", sorted, 50, 50},
+		{"p90", sorted, 90, 90},
+		{"p95 rounds up", sorted, 95, 100},
+		{"p100 is the maximum", sorted, 100, 100},
+		{"p11 rounds up", sorted, 11, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("Percentile(p%g) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentileFloat(t *testing.T) {
+	thousand := make([]float64, 1000)
+	for i := range thousand {
+		thousand[i] = float64(i + 1)
+	}
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 50, 0},
+		{"single", []float64{2.5}, 99, 2.5},
+		{"p0 is the minimum", thousand, 0, 1},
+		{"p50", thousand, 50, 500},
+		{"p99.9 does not round up", thousand, 99.9, 999},
+		{"p100 is the maximum", thousand, 100, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PercentileFloat(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("PercentileFloat(p%g) = %g, want %g", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDistribution(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		want      Distribution
+	}{
+		{"empty", nil, Distribution{}},
+		{"single", []time.Duration{5 * ms}, Distribution{
+			Min: 5 * ms, Mean: 5 * ms, P50: 5 * ms, P90: 5 * ms, P95: 5 * ms, P99: 5 * ms, P999: 5 * ms, Max: 5 * ms,
+		}},
+		{"1 to 1000ms unsorted", milliseconds1To(1000), Distribution{
+			Min: ms, Mean: 500*ms + 500*time.Microsecond, P50: 500 * ms, P90: 900 * ms, P95: 950 * ms,
+			P99: 990 * ms, P999: 999 * ms, Max: 1000 * ms,
+		}},
+		{"outlier", []time.Duration{ms, ms, ms, 97 * ms}, Distribution{
+			Min: ms, Mean: 25 * ms, P50: ms, P90: 97 * ms, P95: 97 * ms, P99: 97 * ms, P999: 97 * ms, Max: 97 * ms,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewDistribution(tt.latencies); got != tt.want {
+				t.Errorf("NewDistribution() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
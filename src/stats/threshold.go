@@ -0,0 +1,172 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is a pass/fail condition on a run metric, parsed from expressions such as
+// "p99<250ms", "error_rate<1%" or "rps>400".
+type Threshold struct {
+	Expression string
+	Metric     string
+	Operator   string
+	Value      float64 // seconds for latency metrics, a fraction for rates
+}
+
+// ThresholdResult is the outcome of a threshold for a finished run.
+type ThresholdResult struct {
+	Threshold
+	Actual float64
+	Passed bool
+}
+
+type thresholdMetric struct {
+	kind  string // latency, rate or number
+	value func(s *Summary) float64
+}
+
+var thresholdMetrics = map[string]thresholdMetric{
+	"min":          {"latency", func(s *Summary) float64 { return s.thresholdLatency().Min.Seconds() }},
+	"mean":         {"latency", func(s *Summary) float64 { return s.thresholdLatency().Mean.Seconds() }},
+	"p50":          {"latency", func(s *Summary) float64 { return s.thresholdLatency().P50.Seconds() }},
+	"p90":          {"latency", func(s *Summary) float64 { return s.thresholdLatency().P90.Seconds() }},
+	"p95":          {"latency", func(s *Summary) float64 { return s.thresholdLatency().P95.Seconds() }},
+	"p99":          {"latency", func(s *Summary) float64 { return s.thresholdLatency().P99.Seconds() }},
+	"p99.9":        {"latency", func(s *Summary) float64 { return s.thresholdLatency().P999.Seconds() }},
+	"max":          {"latency", func(s *Summary) float64 { return s.thresholdLatency().Max.Seconds() }},
+	"error_rate":   {"rate", func(s *Summary) float64 { return s.ErrorRate() }},
+	"success_rate": {"rate", func(s *Summary) float64 { return s.SuccessRate() }},
+	"retry_rate":   {"rate", func(s *Summary) float64 { return s.RetryRate() }},
+	"rps":          {"number", func(s *Summary) float64 { return s.Throughput }},
+	"requests":     {"number", func(s *Summary) float64 { return float64(s.Requests) }},
+	"failed":       {"number", func(s *Summary) float64 { return float64(s.Failed) }},
+	"events":       {"number", func(s *Summary) float64 { return float64(s.Events) }},
+}
+
+// thresholdOperators are matched in order, so two character operators win over their prefix.
+var thresholdOperators = []string{"<=", ">=", "<", ">"}
+
+// ParseThreshold parses "<metric><operator><value>". Latency metrics (min, mean, p50, p90, p95, p99,
+// p99.9, max) take a duration, a bare number meaning milliseconds, rates (error_rate, success_rate,
+// retry_rate) take a percentage or a fraction, and rps, requests, failed and events take a number.
+func ParseThreshold(expression string) (Threshold, error) {
+	t := Threshold{Expression: expression}
+	compact := strings.ReplaceAll(expression, " ", "")
+	index := -1
+	for _, operator := range thresholdOperators {
+		if i := strings.Index(compact, operator); i >= 0 {
+			index = i
+			t.Operator = operator
+			break
+		}
+	}
+	if index < 0 {
+		return t, fmt.Errorf("invalid threshold %q, expected <metric><operator><value> with one of < <= > >=", expression)
+	}
+	t.Metric = strings.ToLower(compact[:index])
+	metric, ok := thresholdMetrics[t.Metric]
+	if !ok {
+		return t, fmt.Errorf("invalid threshold %q, unknown metric %q", expression, t.Metric)
+	}
+	raw := compact[index+len(t.Operator):]
+	var err error
+	switch metric.kind {
+	case "latency":
+		t.Value, err = parseThresholdLatency(raw)
+	case "rate":
+		t.Value, err = parseThresholdRate(raw)
+	default:
+		t.Value, err = strconv.ParseFloat(raw, 64)
+	}
+	if err != nil {
+		return t, fmt.Errorf("invalid threshold %q, bad value %q", expression, raw)
+	}
+	return t, nil
+}
+
+func parseThresholdLatency(raw string) (float64, error) {
+	if ms, err := strconv.ParseFloat(raw, 64); err == nil {
+		return ms / 1000, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, err
+	}
+	return d.Seconds(), nil
+}
+
+func parseThresholdRate(raw string) (float64, error) {
+	if percent, found := strings.CutSuffix(raw, "%"); found {
+		value, err := strconv.ParseFloat(percent, 64)
+		return value / 100, err
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// Evaluate checks the threshold against the summary of a finished run. Latency and rate thresholds
+// fail a run that completed no request, their metrics have no value then.
+func (t Threshold) Evaluate(s *Summary) ThresholdResult {
+	actual := thresholdMetrics[t.Metric].value(s)
+	if s.Requests == 0 && thresholdMetrics[t.Metric].kind != "number" {
+		return ThresholdResult{Threshold: t, Actual: actual, Passed: false}
+	}
+	var passed bool
+	switch t.Operator {
+	case "<":
+		passed = actual < t.Value
+	case "<=":
+		passed = actual <= t.Value
+	case ">":
+		passed = actual > t.Value
+	case ">=":
+		passed = actual >= t.Value
+	}
+	return ThresholdResult{Threshold: t, Actual: actual, Passed: passed}
+}
+
+// Latency reports whether the threshold is on a latency metric, whose values are in seconds.
+func (t Threshold) Latency() bool {
+	return thresholdMetrics[t.Metric].kind == "latency"
+}
+
+// FormatActual renders the measured value in the unit of the metric.
+func (r ThresholdResult) FormatActual() string {
+	switch thresholdMetrics[r.Metric].kind {
+	case "latency":
+		return fmt.Sprintf("%.2fms", r.Actual*1000)
+	case "rate":
+		return fmt.Sprintf("%.2f%%", r.Actual*100)
+	}
+	if r.Actual == float64(int64(r.Actual)) {
+		return fmt.Sprint(int64(r.Actual))
+	}
+	return fmt.Sprintf("%.2f", r.Actual)
+}
+
+// thresholdLatency is the distribution the run reports first, uncorrected only when asked for alone.
+func (s *Summary) thresholdLatency() Distribution {
+	if s.Config.LatencyMode == LatencyUncorrected {
+		return s.UncorrectedLatency
+	}
+	return s.Latency
+}
+
+// PrintThresholds writes the pass/fail table of the run thresholds.
+func (s *Summary) PrintThresholds(w io.Writer) {
+	width := len("threshold")
+	for _, r := range s.Thresholds {
+		width = max(width, len(r.Expression))
+	}
+	fmt.Fprintf(w, "\nThresholds\n%-*s  %12s  %s\n", width, "threshold", "actual", "result")
+	for _, r := range s.Thresholds {
+		result := "PASS"
+		if !r.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(w, "%-*s  %12s  %s\n", width, r.Expression, r.FormatActual(), result)
+	}
+}
@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       Threshold
+		wantErr    bool
+	}{
+		{"p99<250ms", Threshold{Metric: "p99", Operator: "<", Value: 0.25}, false},
+		{"p99.9 <= 1s", Threshold{Metric: "p99.9", Operator: "<=", Value: 1}, false},
+		{"mean<20", Threshold{Metric: "mean", Operator: "<", Value: 0.02}, false},
+		{"P50<1.5ms", Threshold{Metric: "p50", Operator: "<", Value: 0.0015}, false},
+		{"error_rate<1%", Threshold{Metric: "error_rate", Operator: "<", Value: 0.01}, false},
+		{"success_rate>=0.99", Threshold{Metric: "success_rate", Operator: ">=", Value: 0.99}, false},
+		{"rps>400", Threshold{Metric: "rps", Operator: ">", Value: 400}, false},
+		{"failed<=0", Threshold{Metric: "failed", Operator: "<=", Value: 0}, false},
+		{"p99", Threshold{}, true},
+		{"p42<1ms", Threshold{}, true},
+		{"p99<fast", Threshold{}, true},
+		{"error_rate<lots%", Threshold{}, true},
+		{"rps>many", Threshold{}, true},
+		{"p99=1ms", Threshold{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := ParseThreshold(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseThreshold(%q) error = %v, want error %v", tt.expression, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tt.want.Expression = tt.expression
+			if got != tt.want {
+				t.Errorf("ParseThreshold(%q) = %+v, want %+v", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThresholdEvaluate(t *testing.T) {
+	run := &Summary{
+		Config:             RunConfig{LatencyMode: LatencyCorrected},
+		Requests:           100,
+		Successful:         98,
+		Failed:             2,
+		Throughput:         450,
+		Latency:            Distribution{P50: 100 * time.Millisecond, P99: 300 * time.Millisecond},
+		UncorrectedLatency: Distribution{P50: 50 * time.Millisecond, P99: 200 * time.Millisecond},
+	}
+	uncorrected := *run
+	uncorrected.Config.LatencyMode = LatencyUncorrected
+	empty := &Summary{Config: RunConfig{LatencyMode: LatencyCorrected}}
+
+	tests := []struct {
+		expression string
+		summary    *Summary
+		actual     float64
+		passed     bool
+	}{
+		{"p99<250ms", run, 0.3, false},
+		{"p99<=300ms", run, 0.3, true},
+		{"p99<250ms", &uncorrected, 0.2, true},
+		{"p50>60ms", run, 0.1, true},
+		{"error_rate<1%", run, 0.02, false},
+		{"error_rate<=2%", run, 0.02, true},
+		{"success_rate>=98%", run, 0.98, true},
+		{"rps>400", run, 450, true},
+		{"requests>=100", run, 100, true},
+		{"failed<1", run, 2, false},
+		{"error_rate<1%", empty, 0, false},
+		{"success_rate<=100%", empty, 0, false},
+		{"p99<1s", empty, 0, false},
+		{"requests>=0", empty, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			threshold, err := ParseThreshold(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := threshold.Evaluate(tt.summary)
+			if got.Actual != tt.actual || got.Passed != tt.passed {
+				t.Errorf("Evaluate(%q) = actual %g passed %v, want actual %g passed %v",
+					tt.expression, got.Actual, got.Passed, tt.actual, tt.passed)
+			}
+		})
+	}
+}
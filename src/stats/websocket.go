@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WebSocket close codes lgen tells apart, see RFC 6455 section 7.4.1.
+const (
+	CloseNormal    = 1000
+	CloseGoingAway = 1001
+	CloseNoStatus  = 1005 // a close frame without a code
+	CloseAbnormal  = 1006 // the connection ended without a close frame
+)
+
+// Socket is what happened on one WebSocket connection.
+type Socket struct {
+	Connect    time.Duration   // from dialing until the handshake completed
+	RoundTrips []time.Duration // from sending a message until the reply matched to it arrived
+	Sent       int             // messages sent
+	Received   int             // messages received, replies or not
+	CloseCode  int             // code of the close frame received, CloseAbnormal without one
+}
+
+// Unanswered is the number of sent messages no reply was matched to.
+func (s *Socket) Unanswered() int {
+	return max(s.Sent-len(s.RoundTrips), 0)
+}
+
+// SocketSummary summarizes the WebSocket connections of a run that completed their handshake.
+type SocketSummary struct {
+	Connections int
+	Connect     Distribution
+	RoundTrip   Distribution // over every reply matched to a message
+	Sent        int
+	Received    int
+	Unanswered  int
+	CloseCodes  map[int]int
+}
+
+// CloseCodeNames returns the close codes seen, most frequent first.
+func (s SocketSummary) CloseCodeNames() []int {
+	closeCodes := make([]int, 0, len(s.CloseCodes))
+	for code := range s.CloseCodes {
+		closeCodes = append(closeCodes, code)
+	}
+	sort.Slice(closeCodes, func(i, j int) bool {
+		if s.CloseCodes[closeCodes[i]] != s.CloseCodes[closeCodes[j]] {
+			return s.CloseCodes[closeCodes[i]] > s.CloseCodes[closeCodes[j]]
+		}
+		return closeCodes[i] < closeCodes[j]
+	})
+	return closeCodes
+}
+
+func (s SocketSummary) print(w io.Writer) {
+	fmt.Fprintf(w, "WebSocket p50 / p90 / p99 (ms) over %d connections:\n", s.Connections)
+	fmt.Fprintf(w, "  connect     %8.3f / %8.3f / %8.3f\n", milliseconds(s.Connect.P50), milliseconds(s.Connect.P90), milliseconds(s.Connect.P99))
+	fmt.Fprintf(w, "  round trip  %8.3f / %8.3f / %8.3f\n", milliseconds(s.RoundTrip.P50), milliseconds(s.RoundTrip.P90), milliseconds(s.RoundTrip.P99))
+	fmt.Fprintf(w, "Messages sent: %d, received: %d, unanswered: %d\n", s.Sent, s.Received, s.Unanswered)
+	parts := make([]string, 0, len(s.CloseCodes))
+	for _, code := range s.CloseCodeNames() {
+		parts = append(parts, fmt.Sprintf("%d: %d", code, s.CloseCodes[code]))
+	}
+	fmt.Fprintf(w, "Close codes: %s\n", strings.Join(parts, ", "))
+}
+
+// socketSamples collects the WebSocket connections until the run is summarized.
+type socketSamples struct {
+	connect    []time.Duration
+	roundTrips []time.Duration
+	sent       int
+	received   int
+	unanswered int
+	closeCodes map[int]int
+}
+
+func (s *socketSamples) add(socket *Socket) {
+	s.connect = append(s.connect, socket.Connect)
+	s.roundTrips = append(s.roundTrips, socket.RoundTrips...)
+	s.sent += socket.Sent
+	s.received += socket.Received
+	s.unanswered += socket.Unanswered()
+	if s.closeCodes == nil {
+		s.closeCodes = make(map[int]int)
+	}
+	s.closeCodes[socket.CloseCode]++
+}
+
+func (s *socketSamples) summary() SocketSummary {
+	return SocketSummary{
+		Connections: len(s.connect),
+		Connect:     NewDistribution(s.connect),
+		RoundTrip:   NewDistribution(s.roundTrips),
+		Sent:        s.sent,
+		Received:    s.received,
+		Unanswered:  s.unanswered,
+		CloseCodes:  s.closeCodes,
+	}
+}
@@ -0,0 +1,230 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"generator/load/src/stats"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	ProtocolGrpc = "grpc"
+	ProtocolHttp = "http"
+)
+
+const instrumentation = "generator/load"
+
+// exportInterval is how often run metrics are pushed to the collector while the load runs.
+const exportInterval = 5 * time.Second
+
+type Config struct {
+	Endpoint    string  // OTLP endpoint, host:port or a URL, http:// or a bare host:port means plaintext
+	Protocol    string  // grpc or http
+	SampleRatio float64 // fraction of requests getting a client span, 0 exports no traces
+}
+
+// Exporter pushes run metrics, and optionally sampled client spans, to an OTLP endpoint.
+// It is a stats.Sink, spans are started by the executors through StartSpan.
+type Exporter struct {
+	collector      *stats.Collector
+	meterProvider  *sdkmetric.MeterProvider
+	tracerProvider *sdktrace.TracerProvider // nil when no spans are exported
+	attributes     attribute.Set
+
+	requests metric.Int64Counter
+	latency  metric.Float64Histogram
+	events   metric.Int64Counter
+	bytesIn  metric.Int64Counter
+	bytesOut metric.Int64Counter
+}
+
+// Start creates the exporters and installs the global tracer provider and W3C trace context
+// propagator when spans are sampled, so executors inject traceparent headers and metadata.
+func Start(config Config, collector *stats.Collector) (*Exporter, error) {
+	if config.Protocol != ProtocolGrpc && config.Protocol != ProtocolHttp {
+		return nil, fmt.Errorf("invalid OTLP protocol %q, expected grpc or http", config.Protocol)
+	}
+	endpoint := config.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	ctx := context.Background()
+	res := resource.NewSchemaless(attribute.String("service.name", "lgen"))
+
+	var metricExporter sdkmetric.Exporter
+	var err error
+	if config.Protocol == ProtocolGrpc {
+		metricExporter, err = otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpointURL(endpoint))
+	} else {
+		metricExporter, err = otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(signalUrl(endpoint, "/v1/metrics")))
+	}
+	if err != nil {
+		return nil, err
+	}
+	runConfig := collector.Config()
+	e := &Exporter{
+		collector: collector,
+		meterProvider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithResource(res),
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(exportInterval))),
+		),
+		attributes: attribute.NewSet(
+			attribute.String("lgen.protocol", runConfig.Protocol),
+			attribute.String("lgen.mode", runConfig.Mode),
+			attribute.String("lgen.method", runConfig.Method),
+			attribute.String("lgen.target", runConfig.Target),
+		),
+	}
+	if err := e.createInstruments(); err != nil {
+		return nil, err
+	}
+
+	if config.SampleRatio > 0 {
+		var spanExporter sdktrace.SpanExporter
+		if config.Protocol == ProtocolGrpc {
+			spanExporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+		} else {
+			spanExporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(signalUrl(endpoint, "/v1/traces")))
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.tracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithResource(res),
+			sdktrace.WithBatcher(spanExporter),
+			sdktrace.WithSampler(sdktrace.TraceIDRatioBased(config.SampleRatio)),
+		)
+		otel.SetTracerProvider(e.tracerProvider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	}
+	return e, nil
+}
+
+// signalUrl appends the default OTLP/HTTP path of a signal to an endpoint given without a path,
+// the exporters would otherwise post to the root of the collector.
+func signalUrl(endpoint string, path string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || strings.Trim(u.Path, "/") != "" {
+		return endpoint
+	}
+	u.Path = path
+	return u.String()
+}
+
+func (e *Exporter) createInstruments() error {
+	meter := e.meterProvider.Meter(instrumentation)
+	var err error
+	if e.requests, err = meter.Int64Counter("lgen.requests", metric.WithDescription("Completed requests by status and result")); err != nil {
+		return err
+	}
+	if e.latency, err = meter.Float64Histogram("lgen.request.duration", metric.WithUnit("s"),
+		metric.WithDescription("Request latency, measured from the intended send time")); err != nil {
+		return err
+	}
+	if e.events, err = meter.Int64Counter("lgen.stream.events", metric.WithDescription("SSE events and server streamed messages received")); err != nil {
+		return err
+	}
+	if e.bytesOut, err = meter.Int64Counter("lgen.bytes.sent", metric.WithUnit("By")); err != nil {
+		return err
+	}
+	if e.bytesIn, err = meter.Int64Counter("lgen.bytes.received", metric.WithUnit("By")); err != nil {
+		return err
+	}
+	_, err = meter.Int64ObservableGauge("lgen.requests.in_flight",
+		metric.WithDescription("Requests sent and not completed yet"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(e.collector.InFlight(), metric.WithAttributeSet(e.attributes))
+			return nil
+		}))
+	return err
+}
+
+func (e *Exporter) Record(r stats.Result) {
+	ctx := context.Background()
+	result := "success"
+	if !r.Successful {
+		result = "failure"
+	}
+	protocol := e.collector.Config().Protocol
+	e.requests.Add(ctx, 1, metric.WithAttributeSet(e.attributes), metric.WithAttributes(
+		attribute.String("lgen.status", stats.StatusName(protocol, r.Status)),
+		attribute.String("lgen.result", result),
+	))
+	all := metric.WithAttributeSet(e.attributes)
+	e.latency.Record(ctx, r.Latency().Seconds(), all)
+	e.events.Add(ctx, int64(r.Events), all)
+	e.bytesOut.Add(ctx, r.BytesOut, all)
+	e.bytesIn.Add(ctx, r.BytesIn, all)
+}
+
+// Close flushes pending metrics and spans to the endpoint.
+func (e *Exporter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := e.meterProvider.Shutdown(ctx)
+	if e.tracerProvider != nil {
+		if traceErr := e.tracerProvider.Shutdown(ctx); traceErr != nil && err == nil {
+			err = traceErr
+		}
+	}
+	return err
+}
+
+////////////////////////// Request instrumentation /////////////////////////
+
+// StartSpan starts the client span of one request, a no-op span unless the exporter samples traces.
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...))
+}
+
+// InjectHttp adds the traceparent header of the span in ctx to an outgoing HTTP request.
+func InjectHttp(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectGrpc returns ctx with the traceparent of its span added to the outgoing gRPC metadata.
+func InjectGrpc(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for key, value := range carrier {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+	}
+	return ctx
+}
+
+// EndSpan records the outcome of the request on its span and ends it.
+func EndSpan(span trace.Span, r stats.Result, statusKey string) {
+	if !span.IsRecording() {
+		span.End()
+		return
+	}
+	span.SetAttributes(attribute.Int(statusKey, r.Status))
+	if r.Events > 0 {
+		span.SetAttributes(attribute.Int("lgen.events", r.Events))
+	}
+	if !r.Successful {
+		span.SetStatus(codes.Error, r.Error)
+	}
+	span.End(trace.WithTimestamp(r.End))
+}
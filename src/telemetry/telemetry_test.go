@@ -0,0 +1,193 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"generator/load/src/stats"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpStub is an OTLP/HTTP collector keeping the bodies posted to every path.
+type otlpStub struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies map[string][][]byte
+}
+
+func newOtlpStub(t *testing.T) *otlpStub {
+	s := &otlpStub{bodies: make(map[string][][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies[r.URL.Path] = append(s.bodies[r.URL.Path], body)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *otlpStub) posted(path string) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[path]
+}
+
+// resetGlobals undoes the tracer provider and propagator Start installs when sampling spans.
+func resetGlobals(t *testing.T) {
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+}
+
+func testCollector() *stats.Collector {
+	return stats.NewCollector(stats.RunConfig{Protocol: stats.ProtocolHttp, Mode: stats.ModeUnary, Method: "GET", Target: "http://x"})
+}
+
+func TestStartProtocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		wantErr  bool
+	}{
+		{ProtocolGrpc, false},
+		{ProtocolHttp, false},
+		{"", true},
+		{"HTTP", true},
+		{"udp", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			e, err := Start(Config{Endpoint: "127.0.0.1:1", Protocol: tt.protocol}, testCollector())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start(%q) error = %v, want error %v", tt.protocol, err, tt.wantErr)
+			}
+			if e != nil {
+				// There is no collector to flush to, so shut down without waiting for one.
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				e.meterProvider.Shutdown(ctx)
+			}
+		})
+	}
+}
+
+func TestSignalUrl(t *testing.T) {
+	tests := []struct {
+		endpoint, want string
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/metrics"},
+		{"https://collector:4318/", "https://collector:4318/v1/metrics"},
+		{"https://collector/otlp/v1/metrics", "https://collector/otlp/v1/metrics"},
+	}
+	for _, tt := range tests {
+		if got := signalUrl(tt.endpoint, "/v1/metrics"); got != tt.want {
+			t.Errorf("signalUrl(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestExporterPushesOnClose(t *testing.T) {
+	stub := newOtlpStub(t)
+	e, err := Start(Config{Endpoint: stub.URL, Protocol: ProtocolHttp}, testCollector())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	e.Record(stats.Result{Start: start, End: start.Add(10 * time.Millisecond), Successful: true, Status: 200, BytesIn: 20})
+	e.Record(stats.Result{Start: start, End: start.Add(20 * time.Millisecond), Status: 503})
+	if got := stub.posted("/v1/metrics"); len(got) != 0 {
+		t.Fatalf("metrics pushed %d times before the export interval, want none", len(got))
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	bodies := stub.posted("/v1/metrics")
+	if len(bodies) != 1 {
+		t.Fatalf("metrics pushed %d times on Close, want once", len(bodies))
+	}
+	var request collectormetrics.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(bodies[0], &request); err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, rm := range request.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				names[m.GetName()] = true
+			}
+		}
+	}
+	for _, want := range []string{"lgen.requests", "lgen.request.duration", "lgen.requests.in_flight", "lgen.bytes.received"} {
+		if !names[want] {
+			t.Errorf("pushed metrics %v miss %s", names, want)
+		}
+	}
+	if got := stub.posted("/v1/traces"); len(got) != 0 {
+		t.Errorf("spans pushed %d times without sampling, want none", len(got))
+	}
+}
+
+var traceparent = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`)
+
+func TestInject(t *testing.T) {
+	resetGlobals(t)
+	stub := newOtlpStub(t)
+	e, err := Start(Config{Endpoint: stub.URL, Protocol: ProtocolHttp, SampleRatio: 1}, testCollector())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := StartSpan(context.Background(), "GET")
+	header := http.Header{}
+	InjectHttp(ctx, header)
+	if got := header.Get("traceparent"); !traceparent.MatchString(got) {
+		t.Errorf("traceparent header = %q, want a sampled W3C trace context", got)
+	}
+	md, _ := metadata.FromOutgoingContext(InjectGrpc(ctx))
+	if got := md.Get("traceparent"); len(got) != 1 || got[0] != header.Get("traceparent") {
+		t.Errorf("traceparent metadata = %q, want %q", got, header.Get("traceparent"))
+	}
+	EndSpan(span, stats.Result{End: time.Now(), Successful: true, Status: 200}, "http.response.status_code")
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := stub.posted("/v1/traces"); len(got) != 1 {
+		t.Errorf("spans pushed %d times on Close, want once", len(got))
+	}
+}
+
+func TestInjectUnsampled(t *testing.T) {
+	resetGlobals(t)
+	e, err := Start(Config{Endpoint: newOtlpStub(t).URL, Protocol: ProtocolHttp}, testCollector())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	ctx, span := StartSpan(context.Background(), "GET")
+	defer span.End()
+	header := http.Header{}
+	InjectHttp(ctx, header)
+	if got := header.Get("traceparent"); got != "" {
+		t.Errorf("traceparent header = %q without sampling, want none", got)
+	}
+	if md, _ := metadata.FromOutgoingContext(InjectGrpc(ctx)); len(md.Get("traceparent")) != 0 {
+		t.Errorf("traceparent metadata = %q without sampling, want none", md.Get("traceparent"))
+	}
+}
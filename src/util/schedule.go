@@ -0,0 +1,77 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// Schedule paces request dispatch at a fixed rate and hands out the intended
+// send time of every request, so latencies measured from it are not hidden by
+// a stalled server (coordinated omission).
+type Schedule struct {
+	start    time.Time
+	interval time.Duration // zero means no pacing, every request is due immediately
+}
+
+func NewSchedule(rate float64) *Schedule {
+	var interval time.Duration = 0
+	if rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+	return &Schedule{
+		start:    time.Now(),
+		interval: interval,
+	}
+}
+
+// Next blocks until the i-th request is due and returns the time it was meant to be sent.
+func (s *Schedule) Next(i int) time.Time {
+	if s.interval == 0 {
+		return time.Now()
+	}
+	intended := s.start.Add(time.Duration(i) * s.interval)
+	if wait := time.Until(intended); wait > 0 {
+		time.Sleep(wait)
+	}
+	return intended
+}
+
+// Workers is the number of workers running n requests with a concurrency of conc, 0 or less gives
+// every request its own worker.
+func Workers(conc int, n int) int {
+	if conc <= 0 || conc > n {
+		return n
+	}
+	return conc
+}
+
+// Dispatch runs the n requests of a run on a pool of Workers(conc, n) workers, handing request i
+// out once it is due to the first free worker, whose index run gets along with i. A request that
+// finds every worker busy waits for one but keeps its intended time, so latencies measured from it
+// include the delay a stalled server caused. Without pacing there is no schedule to fall behind,
+// the intended time is when a worker picked the request up.
+func (s *Schedule) Dispatch(n int, conc int, run func(worker int, i int, intended time.Time)) {
+	type request struct {
+		i        int
+		intended time.Time
+	}
+	requests := make(chan request)
+	var wg sync.WaitGroup
+	for w := 0; w < Workers(conc, n); w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for r := range requests {
+				if s.interval == 0 {
+					r.intended = time.Now()
+				}
+				run(worker, r.i, r.intended)
+			}
+		}(w)
+	}
+	for i := 0; i < n; i++ {
+		requests <- request{i, s.Next(i)}
+	}
+	close(requests)
+	wg.Wait()
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A start in the past makes every request overdue, so Next returns without sleeping.
+	start := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name     string
+		interval time.Duration
+		i        int
+		want     time.Time
+	}{
+		{"first request", 10 * time.Millisecond, 0, start},
+		{"tenth request", 10 * time.Millisecond, 10, start.Add(100 * time.Millisecond)},
+		{"overdue keeps its slot", time.Second, 3, start.Add(3 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Schedule{start: start, interval: tt.interval}
+			if got := s.Next(tt.i); !got.Equal(tt.want) {
+				t.Errorf("Next(%d) = %v, want %v", tt.i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNextUnpaced(t *testing.T) {
+	s := NewSchedule(0)
+	before := time.Now()
+	got := s.Next(1000)
+	if got.Before(before) || time.Since(got) > time.Second {
+		t.Errorf("Next without a rate = %v, want the current time", got)
+	}
+}
+
+func TestScheduleNextWaits(t *testing.T) {
+	s := NewSchedule(20) // one request every 50ms
+	got := s.Next(2)
+	if want := s.start.Add(100 * time.Millisecond); !got.Equal(want) {
+		t.Errorf("Next(2) = %v, want %v", got, want)
+	}
+	if time.Now().Before(got) {
+		t.Errorf("Next(2) returned before the request was due")
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	tests := []struct {
+		conc, n, want int
+	}{
+		{0, 10, 10},
+		{-1, 10, 10},
+		{4, 10, 4},
+		{10, 10, 10},
+		{20, 10, 10},
+		{1, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Workers(tt.conc, tt.n); got != tt.want {
+			t.Errorf("Workers(%d, %d) = %d, want %d", tt.conc, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleDispatch(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		conc int
+	}{
+		{"one worker", 20, 1},
+		{"bounded pool", 20, 4},
+		{"worker per request", 20, 0},
+		{"no requests", 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			seen := make(map[int]bool)
+			var running, peak atomic.Int64
+			workers := Workers(tt.conc, tt.n)
+			NewSchedule(0).Dispatch(tt.n, tt.conc, func(worker int, i int, intended time.Time) {
+				if worker < 0 || worker >= workers {
+					t.Errorf("request %d ran on worker %d, want one of %d workers", i, worker, workers)
+				}
+				now := running.Add(1)
+				for {
+					old := peak.Load()
+					if now <= old || peak.CompareAndSwap(old, now) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+				mu.Lock()
+				seen[i] = true
+				mu.Unlock()
+			})
+			if len(seen) != tt.n {
+				t.Errorf("ran %d distinct requests, want %d", len(seen), tt.n)
+			}
+			if max := int64(workers); peak.Load() > max {
+				t.Errorf("%d requests ran at once, want at most %d", peak.Load(), max)
+			}
+		})
+	}
+}
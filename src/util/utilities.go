@@ -7,7 +7,6 @@ func GenerateFile(file_name string, file_size int) (string, error) {
 	var filepath string  = ""
 	var err error
 	filepath, err = os.Getwd()
-	println(os.Getwd())
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,295 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"generator/load/src/assert"
+	"generator/load/src/stats"
+	"generator/load/src/telemetry"
+	"generator/load/src/util"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CorrelateEcho matches replies to messages by their whole payload, for servers that echo messages back.
+const CorrelateEcho = "echo"
+
+// closeWait caps how long a connection waits for the server to answer its close frame.
+const closeWait = 5 * time.Second
+
+type WsReq struct {
+	destination string        // ws:// or wss:// URL of the endpoint
+	reqNum      int           // number of connections to open
+	timeout     time.Duration // limit of the handshake, and of the wait for replies after the last message
+	rate        float64       // connections per second to open, 0 opens them all at once
+	template    string        // message sent, with {{id}}, {{conn}}, {{seq}} and {{time}} replaced per message
+	binary      bool          // whether messages are sent as binary instead of text frames
+	messages    int           // messages sent per connection
+	interval    time.Duration // between two messages of a connection, 0 sends them back to back
+	correlate   string        // CorrelateEcho or the JSON path of the message id in replies
+}
+
+////////////////////////// Exported Methods /////////////////////////
+
+func GenerateWsReq(destination string, reqNum int, timeout int, rate float64, template string, binary bool, messages int, messageRate float64, correlate string) *WsReq {
+	var interval time.Duration = 0
+	if messageRate > 0 {
+		interval = time.Duration(float64(time.Second) / messageRate)
+	}
+	return &WsReq{
+		destination: destination,
+		reqNum:      reqNum,
+		timeout:     time.Duration(timeout) * time.Second,
+		rate:        rate,
+		template:    template,
+		binary:      binary,
+		messages:    messages,
+		interval:    interval,
+		correlate:   correlate,
+	}
+}
+
+// ValidCorrelation checks the value of --correlate: echo or a JSON path such as $.id.
+func ValidCorrelation(correlate string) bool {
+	return correlate == CorrelateEcho || strings.HasPrefix(correlate, "$")
+}
+
+func (w *WsReq) GenerateWsLoad(collector *stats.Collector) {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: w.timeout,
+	}
+	schedule := util.NewSchedule(w.rate)
+	schedule.Dispatch(w.reqNum, 0, func(worker int, i int, intended time.Time) {
+		w.generate_one_ws_load(dialer, collector, worker, i, intended)
+	})
+}
+
+///////////////////////// Internal Methods /////////////////////////
+
+// pending holds the send times of the messages waiting for a reply, by correlation key.
+type pending struct {
+	mu    sync.Mutex
+	sent  map[string][]time.Time
+	count int
+	done  chan struct{} // closed once every message sent so far was answered, after the last one
+	last  bool          // whether the last message was sent
+}
+
+func newPending() *pending {
+	return &pending{sent: make(map[string][]time.Time), done: make(chan struct{})}
+}
+
+func (p *pending) add(key string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent[key] = append(p.sent[key], at)
+	p.count++
+}
+
+// match returns the send time of the oldest message waiting under key.
+func (p *pending) match(key string) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	times := p.sent[key]
+	if len(times) == 0 {
+		return time.Time{}, false
+	}
+	if len(times) == 1 {
+		delete(p.sent, key)
+	} else {
+		p.sent[key] = times[1:]
+	}
+	p.count--
+	p.check()
+	return times[0], true
+}
+
+// finish marks the last message as sent.
+func (p *pending) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.last = true
+	p.check()
+}
+
+func (p *pending) check() {
+	if p.last && p.count == 0 {
+		select {
+		case <-p.done:
+		default:
+			close(p.done)
+		}
+	}
+}
+
+func (w *WsReq) generate_one_ws_load(dialer *websocket.Dialer, collector *stats.Collector, worker int, request int, intended time.Time) {
+	result := stats.Result{
+		Worker:   worker,
+		Request:  request,
+		Intended: intended,
+		Start:    time.Now(),
+	}
+	collector.Dispatch()
+	ctx, span := telemetry.StartSpan(context.Background(), "lgen websocket",
+		attribute.String("url.full", w.destination))
+	defer func() { telemetry.EndSpan(span, result, "http.response.status_code") }()
+
+	header := http.Header{}
+	telemetry.InjectHttp(ctx, header)
+	conn, resp, err := dialer.DialContext(ctx, w.destination, header)
+	if err != nil {
+		result.End = time.Now()
+		if resp != nil {
+			result.Status = resp.StatusCode
+			result.ErrorClass = stats.ClassifyHttpStatus(resp.StatusCode)
+		}
+		if result.ErrorClass == "" {
+			result.ErrorClass = stats.ClassifyError(err)
+		}
+		result.Error = err.Error()
+		collector.Record(result)
+		return
+	}
+	defer conn.Close()
+	socket := &stats.Socket{Connect: time.Since(result.Start), CloseCode: stats.CloseAbnormal}
+	result.Status = resp.StatusCode
+	result.Socket = socket
+
+	waiting := newPending()
+	var readErr error
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		readErr = w.read_replies(conn, waiting, socket, &result)
+	}()
+
+	writeErr := w.send_messages(conn, waiting, socket, &result, request, read)
+	waiting.finish()
+	if writeErr == nil {
+		select {
+		case <-waiting.done:
+		case <-read:
+		case <-time.After(w.timeout):
+		}
+		// The server answers the close frame with its own, ending read_replies.
+		message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeWait))
+		conn.SetReadDeadline(time.Now().Add(closeWait))
+	} else {
+		conn.Close()
+	}
+	<-read
+
+	result.End = time.Now()
+	result.Events = socket.Received
+	var closeErr *websocket.CloseError
+	switch {
+	case errors.As(readErr, &closeErr):
+		socket.CloseCode = closeErr.Code
+	case writeErr != nil:
+		result.ErrorClass = stats.ClassifyError(writeErr)
+		result.Error = writeErr.Error()
+	case readErr != nil:
+		result.ErrorClass = stats.ClassifyError(readErr)
+		result.Error = readErr.Error()
+	}
+	if result.ErrorClass == "" {
+		switch socket.CloseCode {
+		case stats.CloseNormal, stats.CloseGoingAway, stats.CloseNoStatus:
+			result.Successful = true
+		default:
+			result.ErrorClass = stats.ErrorWsClose
+			result.Error = fmt.Sprintf("closed with %d", socket.CloseCode)
+			if closeErr != nil && closeErr.Text != "" {
+				result.Error += ": " + closeErr.Text
+			}
+		}
+	}
+	collector.Record(result)
+}
+
+// send_messages sends the messages of a connection, paced by the interval, until all are sent or
+// the connection was closed under it.
+func (w *WsReq) send_messages(conn *websocket.Conn, waiting *pending, socket *stats.Socket, result *stats.Result, request int, read <-chan struct{}) error {
+	messageType := websocket.TextMessage
+	if w.binary {
+		messageType = websocket.BinaryMessage
+	}
+	start := time.Now()
+	for seq := 0; seq < w.messages; seq++ {
+		if w.interval > 0 {
+			select {
+			case <-read:
+				return nil // read_replies reports why the connection ended
+			case <-time.After(time.Until(start.Add(time.Duration(seq) * w.interval))):
+			}
+		}
+		id := fmt.Sprintf("%d-%d", request, seq)
+		payload := strings.NewReplacer(
+			"{{id}}", id,
+			"{{conn}}", strconv.Itoa(request),
+			"{{seq}}", strconv.Itoa(seq),
+			"{{time}}", strconv.FormatInt(time.Now().UnixNano(), 10),
+		).Replace(w.template)
+		key := id
+		if w.correlate == CorrelateEcho {
+			key = payload
+		}
+		waiting.add(key, time.Now())
+		if err := conn.WriteMessage(messageType, []byte(payload)); err != nil {
+			select {
+			case <-read:
+				return nil
+			default:
+				return err
+			}
+		}
+		socket.Sent++
+		result.BytesOut += int64(len(payload))
+	}
+	return nil
+}
+
+// read_replies reads until the connection ends and times the replies it can match to a message,
+// the error it returns is a *websocket.CloseError when the server sent a close frame.
+func (w *WsReq) read_replies(conn *websocket.Conn, waiting *pending, socket *stats.Socket, result *stats.Result) error {
+	for {
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		received := time.Now()
+		socket.Received++
+		result.BytesIn += int64(len(payload))
+		if sent, ok := waiting.match(w.correlation_key(payload)); ok {
+			socket.RoundTrips = append(socket.RoundTrips, received.Sub(sent))
+		}
+	}
+}
+
+// correlation_key is the key a reply is matched under: the whole payload when echoing, else the
+// value at the correlation path of the JSON reply.
+func (w *WsReq) correlation_key(payload []byte) string {
+	if w.correlate == CorrelateEcho {
+		return string(payload)
+	}
+	var document any
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return ""
+	}
+	value, ok := assert.Lookup(document, w.correlate)
+	if !ok {
+		return ""
+	}
+	return assert.Format(value)
+}
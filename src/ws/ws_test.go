@@ -0,0 +1,141 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"generator/load/src/stats"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsServer upgrades every request and hands the connection to serve, which returns the close
+// code to end the connection with, 0 once the client closed it.
+func wsServer(t *testing.T, serve func(conn *websocket.Conn) int) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if code := serve(conn); code != 0 {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, "bye"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// echo writes every message back until the client closes the connection.
+func echo(conn *websocket.Conn) int {
+	for {
+		messageType, payload, err := conn.ReadMessage()
+		if err != nil {
+			return 0
+		}
+		conn.WriteMessage(messageType, payload)
+	}
+}
+
+func runWs(t *testing.T, w *WsReq) *stats.Summary {
+	t.Helper()
+	collector := stats.NewCollector(stats.RunConfig{Protocol: stats.ProtocolWebsocket, Requests: w.reqNum})
+	collector.Start()
+	w.GenerateWsLoad(collector)
+	summary, err := collector.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestWsRoundTrip(t *testing.T) {
+	// replyTo answers JSON messages with the id of the message they reply to, and a message without one.
+	replyTo := func(conn *websocket.Conn) int {
+		for {
+			_, payload, err := conn.ReadMessage()
+			if err != nil {
+				return 0
+			}
+			var message struct{ Id string }
+			json.Unmarshal(payload, &message)
+			conn.WriteJSON(map[string]any{"reply": map[string]string{"to": message.Id}})
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"tick"}`))
+		}
+	}
+	tests := []struct {
+		name      string
+		serve     func(conn *websocket.Conn) int
+		template  string
+		binary    bool
+		correlate string
+		received  int
+	}{
+		{"echo text", echo, "hello {{conn}} {{seq}}", false, CorrelateEcho, 6},
+		{"echo binary", echo, "{{id}}", true, CorrelateEcho, 6},
+		{"json id", replyTo, `{"id":"{{id}}"}`, false, "$.reply.to", 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := wsServer(t, tt.serve)
+			summary := runWs(t, GenerateWsReq(url, 2, 5, 0, tt.template, tt.binary, 3, 0, tt.correlate))
+			if summary.Successful != 2 {
+				t.Errorf("%d of 2 connections succeeded, errors %v", summary.Successful, summary.Errors)
+			}
+			s := summary.Sockets
+			if s.Connections != 2 || s.Sent != 6 || s.Received != tt.received || s.Unanswered != 0 {
+				t.Errorf("sockets = %d connections, %d sent, %d received, %d unanswered, want 2, 6, %d, 0",
+					s.Connections, s.Sent, s.Received, tt.received, s.Unanswered)
+			}
+			if s.RoundTrip.Max <= 0 {
+				t.Error("no round trip was timed")
+			}
+			if s.CloseCodes[stats.CloseNormal] != 2 {
+				t.Errorf("close codes = %v, want 2 normal closures", s.CloseCodes)
+			}
+		})
+	}
+}
+
+func TestWsFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		serve      func(conn *websocket.Conn) int
+		errorClass string
+		closeCode  int
+	}{
+		{"server error close", func(conn *websocket.Conn) int { return websocket.CloseInternalServerErr }, stats.ErrorWsClose, websocket.CloseInternalServerErr},
+		{"going away", func(conn *websocket.Conn) int { return websocket.CloseGoingAway }, "", websocket.CloseGoingAway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := runWs(t, GenerateWsReq(wsServer(t, tt.serve), 1, 5, 0, "x", false, 0, 0, CorrelateEcho))
+			if tt.errorClass == "" && summary.Successful != 1 {
+				t.Errorf("connection failed with %v, want a success", summary.Errors)
+			}
+			if tt.errorClass != "" && summary.ErrorClasses[tt.errorClass] != 1 {
+				t.Errorf("error classes = %v, want one %s", summary.ErrorClasses, tt.errorClass)
+			}
+			if summary.Sockets.CloseCodes[tt.closeCode] != 1 {
+				t.Errorf("close codes = %v, want %d", summary.Sockets.CloseCodes, tt.closeCode)
+			}
+		})
+	}
+}
+
+func TestWsHandshakeRejected(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	summary := runWs(t, GenerateWsReq("ws"+strings.TrimPrefix(server.URL, "http"), 1, 5, 0, "x", false, 1, 0, CorrelateEcho))
+	if summary.Failed != 1 || summary.StatusCodes[http.StatusNotFound] != 1 || summary.ErrorClasses[stats.ErrorHttp4xx] != 1 {
+		t.Errorf("failed %d, statuses %v, classes %v, want one 404 http_4xx failure", summary.Failed, summary.StatusCodes, summary.ErrorClasses)
+	}
+	if summary.Sockets.Connections != 0 {
+		t.Errorf("%d connections counted, want none", summary.Sockets.Connections)
+	}
+}